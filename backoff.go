@@ -0,0 +1,222 @@
+package clickhouse
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Codes of server exceptions which mean that server is overloaded
+const (
+	ErrCodeTooManySimultaneousQueries = 202
+	ErrCodeMemoryLimitExceeded        = 241
+	ErrCodeTooManyParts               = 252
+)
+
+// BackoffOptions control AdaptiveLimiter, zero values are replaced with defaults
+type BackoffOptions struct {
+	// MaxConcurrency is amount of concurrent requests without overload, default is 32
+	MaxConcurrency int
+	// MinConcurrency is lowest amount of concurrent requests, default is 1
+	MinConcurrency int
+	// MinDelay is pause between requests after first overload error, default is 50ms
+	MinDelay time.Duration
+	// MaxDelay limits pause between requests, default is 5s
+	MaxDelay time.Duration
+	// Recovery is factor of pause after successful request, concurrency grows by 1-Recovery per successful
+	// request. Default is 0.9, so limit grows by one after ten successful requests
+	Recovery float64
+	// Codes are exception codes of overload, default are 202, 241 and 252. Http status 503 is always overload
+	Codes []int
+}
+
+// AdaptiveLimiter lowers concurrency and rate of requests when server reports overload and increases them
+// gradually while requests succeed. Concurrency is halved and pause between requests is doubled on each overload:
+//
+//	conn.Use(clickhouse.NewAdaptiveLimiter(clickhouse.BackoffOptions{MaxConcurrency: 16}).Interceptor())
+//
+// Pings are not limited, streaming request keeps slot until body is closed. Failed requests which are not
+// overload do not change limits
+type AdaptiveLimiter struct {
+	opts  BackoffOptions
+	codes map[int]bool
+
+	mx       sync.Mutex
+	limit    float64
+	inFlight int
+	delay    time.Duration
+	next     time.Time
+	// decreased is end of window started by last decrease, overloads of requests sent before it are ignored
+	decreased time.Time
+	changed   chan struct{}
+}
+
+func NewAdaptiveLimiter(opts BackoffOptions) *AdaptiveLimiter {
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = 32
+	}
+	if opts.MinConcurrency <= 0 {
+		opts.MinConcurrency = 1
+	}
+	if opts.MinConcurrency > opts.MaxConcurrency {
+		opts.MinConcurrency = opts.MaxConcurrency
+	}
+	if opts.MinDelay <= 0 {
+		opts.MinDelay = 50 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 5 * time.Second
+	}
+	if opts.MaxDelay < opts.MinDelay {
+		opts.MaxDelay = opts.MinDelay
+	}
+	if opts.Recovery <= 0 || opts.Recovery >= 1 {
+		opts.Recovery = 0.9
+	}
+	if opts.Codes == nil {
+		opts.Codes = []int{ErrCodeTooManySimultaneousQueries, ErrCodeMemoryLimitExceeded, ErrCodeTooManyParts}
+	}
+	codes := make(map[int]bool, len(opts.Codes))
+	for _, code := range opts.Codes {
+		codes[code] = true
+	}
+	return &AdaptiveLimiter{
+		opts:    opts,
+		codes:   codes,
+		limit:   float64(opts.MaxConcurrency),
+		changed: make(chan struct{}),
+	}
+}
+
+// State returns current limit of concurrent requests and pause between requests
+func (l *AdaptiveLimiter) State() (concurrency int, delay time.Duration) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return int(l.limit), l.delay
+}
+
+// Interceptor returns interceptor which waits for allowed slot before request, wait time is added to Call.QueueWait
+func (l *AdaptiveLimiter) Interceptor() Interceptor {
+	return func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			if call.Kind == CallPing {
+				return next(call)
+			}
+			start := time.Now()
+			err := l.acquire(call)
+			call.QueueWait += time.Since(start)
+			if err != nil {
+				return nil, err
+			}
+
+			body, err := next(call)
+			// other errors, e.g. network ones or cancelled context, say nothing about server load
+			switch {
+			case l.isOverload(call, err):
+				l.feedback(true)
+			case err == nil:
+				l.feedback(false)
+			}
+			if err != nil || body == nil {
+				l.release()
+				return body, err
+			}
+			return &releaseBody{ReadCloser: body, release: l.release}, nil
+		}
+	}
+}
+
+func (l *AdaptiveLimiter) isOverload(call *Call, err error) bool {
+	if call.StatusCode() == http.StatusServiceUnavailable {
+		return true
+	}
+	var dbErr *DbError
+	return errors.As(err, &dbErr) && l.codes[dbErr.Code()]
+}
+
+func (l *AdaptiveLimiter) acquire(call *Call) error {
+	ctx := call.Query.Context()
+	for {
+		l.mx.Lock()
+		now := time.Now()
+		if l.inFlight < int(l.limit) && !now.Before(l.next) {
+			l.inFlight++
+			l.next = now.Add(l.delay)
+			l.mx.Unlock()
+			return nil
+		}
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if l.inFlight < int(l.limit) {
+			timer = time.NewTimer(l.next.Sub(now))
+			wait = timer.C
+		}
+		changed := l.changed
+		l.mx.Unlock()
+
+		var err error
+		select {
+		case <-changed:
+		case <-wait:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// feedback lowers limits on overload, at most once per delay window, and raises them after successful request
+func (l *AdaptiveLimiter) feedback(overload bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if overload {
+		now := time.Now()
+		if now.Before(l.decreased) {
+			// requests which were in flight together report same overload
+			return
+		}
+		l.limit /= 2
+		if l.limit < float64(l.opts.MinConcurrency) {
+			l.limit = float64(l.opts.MinConcurrency)
+		}
+		l.delay *= 2
+		if l.delay < l.opts.MinDelay {
+			l.delay = l.opts.MinDelay
+		}
+		if l.delay > l.opts.MaxDelay {
+			l.delay = l.opts.MaxDelay
+		}
+		l.next = now.Add(l.delay)
+		l.decreased = l.next
+	} else {
+		l.limit += 1 - l.opts.Recovery
+		if l.limit > float64(l.opts.MaxConcurrency) {
+			l.limit = float64(l.opts.MaxConcurrency)
+		}
+		l.delay = time.Duration(float64(l.delay) * l.opts.Recovery)
+		if l.delay < l.opts.MinDelay/10 {
+			l.delay = 0
+		}
+	}
+	l.notify()
+}
+
+func (l *AdaptiveLimiter) release() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.inFlight--
+	l.notify()
+}
+
+// notify wakes waiting requests
+func (l *AdaptiveLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
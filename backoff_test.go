@@ -0,0 +1,161 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	var overload int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "unavailable"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "Service unavailable")
+		case atomic.LoadInt32(&overload) == 1:
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, "Code: 202, e.displayText() = DB::Exception: Too many simultaneous queries. Maximum: 100")
+		case strings.Contains(string(body), "missing"):
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist")
+		default:
+			io.WriteString(w, "1\n")
+		}
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+	limiter := NewAdaptiveLimiter(BackoffOptions{MaxConcurrency: 8, MinDelay: 10 * time.Millisecond, MaxDelay: 30 * time.Millisecond, Recovery: 0.5})
+	conn.Use(limiter.Interceptor())
+
+	assert.NoError(t, NewQuery("SELECT 1").Exec(conn))
+	concurrency, delay := limiter.State()
+	assert.Equal(t, 8, concurrency)
+	assert.Equal(t, time.Duration(0), delay)
+
+	// other errors are not overload
+	assert.Error(t, NewQuery("SELECT * FROM missing").Exec(conn))
+	concurrency, _ = limiter.State()
+	assert.Equal(t, 8, concurrency)
+
+	atomic.StoreInt32(&overload, 1)
+	assert.Error(t, NewQuery("SELECT 1").Exec(conn))
+	concurrency, delay = limiter.State()
+	assert.Equal(t, 4, concurrency)
+	assert.Equal(t, 10*time.Millisecond, delay)
+
+	start := time.Now()
+	var call *Call
+	conn.Use(func(next Handler) Handler {
+		return func(c *Call) (io.ReadCloser, error) {
+			call = c
+			return next(c)
+		}
+	})
+	assert.Error(t, NewQuery("SELECT 1").Exec(conn))
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+	assert.True(t, call.QueueWait > 0)
+	_, err := NewQuery("SELECT unavailable").Stream(conn)
+	assert.Error(t, err)
+	concurrency, delay = limiter.State()
+	assert.Equal(t, 1, concurrency)
+	assert.Equal(t, 30*time.Millisecond, delay)
+
+	// network errors do not raise limits
+	down := NewConn("http://127.0.0.1:1", NewHttpTransport())
+	down.Use(limiter.Interceptor())
+	for i := 0; i < 3; i++ {
+		assert.Error(t, NewQuery("SELECT 1").Exec(down))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q := NewQuery("SELECT 1")
+	q.SetContext(ctx)
+	assert.Error(t, q.Exec(conn))
+	concurrency, delay = limiter.State()
+	assert.Equal(t, 1, concurrency)
+	assert.Equal(t, 30*time.Millisecond, delay)
+
+	// limits recover gradually
+	atomic.StoreInt32(&overload, 0)
+	assert.NoError(t, NewQuery("SELECT 1").Exec(conn))
+	concurrency, delay = limiter.State()
+	assert.Equal(t, 1, concurrency)
+	assert.Equal(t, 15*time.Millisecond, delay)
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, NewQuery("SELECT 1").Exec(conn))
+	}
+	concurrency, delay = limiter.State()
+	assert.Equal(t, 8, concurrency)
+	assert.Equal(t, time.Duration(0), delay)
+}
+
+func TestAdaptiveLimiter_Concurrency(t *testing.T) {
+	tr := newBlockTransport()
+	conn := NewConn(getHost(), tr)
+	limiter := NewAdaptiveLimiter(BackoffOptions{MaxConcurrency: 1})
+	conn.Use(limiter.Interceptor())
+
+	done := make(chan error)
+	go func() { done <- NewQuery("first").Exec(conn) }()
+	assert.Equal(t, "first", <-tr.started)
+
+	// pings are not limited
+	other := NewConn(getHost(), getMockTransport("1\n"))
+	other.Use(limiter.Interceptor())
+	assert.NoError(t, other.Ping())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	q := NewQuery("second")
+	q.SetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, q.Exec(conn))
+
+	go func() { done <- NewQuery("third").Exec(conn) }()
+	tr.release <- struct{}{}
+	assert.NoError(t, <-done)
+	assert.Equal(t, "third", <-tr.started)
+	tr.release <- struct{}{}
+	assert.NoError(t, <-done)
+}
+
+func TestAdaptiveLimiter_OverloadBurst(t *testing.T) {
+	const n = 4
+	var arrived sync.WaitGroup
+	arrived.Add(n)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// all requests are in flight when server answers
+		arrived.Done()
+		arrived.Wait()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "Service unavailable")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+	limiter := NewAdaptiveLimiter(BackoffOptions{MaxConcurrency: 16, MinDelay: time.Second, MaxDelay: 10 * time.Second})
+	conn.Use(limiter.Interceptor())
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Error(t, NewQuery("SELECT 1").Exec(conn))
+		}()
+	}
+	wg.Wait()
+
+	// burst of overloads is one decrease
+	concurrency, delay := limiter.State()
+	assert.Equal(t, 8, concurrency)
+	assert.Equal(t, time.Second, delay)
+}
@@ -0,0 +1,298 @@
+package clickhouse
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions control CachedConn
+type CacheOptions struct {
+	// TTL is time of keeping result, default is one minute
+	TTL time.Duration
+	// MaxBytes limits total size of cached responses, least recently used are evicted first. Default is 64 MiB
+	MaxBytes int64
+}
+
+const (
+	defaultCacheTTL      = time.Minute
+	defaultCacheMaxBytes = 64 << 20
+)
+
+// CachedConn is Connector which keeps responses of read-only queries in memory:
+//
+//	cached := clickhouse.NewCachedConn(conn, clickhouse.CacheOptions{TTL: 10 * time.Second})
+//	err := clickhouse.NewQuery("SELECT ... heavy aggregation").ExecScan(cached, &report)
+//
+// Key of response is host, statement with normalized whitespace, bound arguments and query params except query_id.
+// Only SELECT, WITH, SHOW, DESCRIBE and EXISTS queries without external tables are cached, see Query.SkipCache.
+// Concurrent identical queries share one request instead of sending same request. It is not bound to context
+// of any caller, every caller waits until its own context is done and request is cancelled when nobody waits for it
+type CachedConn struct {
+	conn Connector
+	ttl  time.Duration
+	max  int64
+
+	mx       sync.Mutex
+	items    map[string]*list.Element
+	lru      *list.List
+	size     int64
+	inflight map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	key     string
+	res     string
+	expires time.Time
+}
+
+// cacheCall is request which is waited by identical queries
+type cacheCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	res     string
+	err     error
+}
+
+func NewCachedConn(conn Connector, opts CacheOptions) *CachedConn {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultCacheMaxBytes
+	}
+	return &CachedConn{
+		conn:     conn,
+		ttl:      opts.TTL,
+		max:      opts.MaxBytes,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// Exec returns cached response or passes query to connection
+func (c *CachedConn) Exec(q Query, readOnly bool) (string, error) {
+	key, ok := cacheKey(c.conn.GetHost(), q)
+	if !ok {
+		return c.conn.Exec(q, readOnly)
+	}
+
+	ctx := q.Context()
+	for {
+		c.mx.Lock()
+		if res, ok := c.get(key); ok {
+			c.mx.Unlock()
+			return res, nil
+		}
+		call, ok := c.inflight[key]
+		if !ok {
+			call = c.start(key, q, readOnly)
+		}
+		call.waiters++
+		c.mx.Unlock()
+
+		select {
+		case <-call.done:
+			// shared request is cancelled only when all its callers left, so it is repeated for this one
+			if errors.Is(call.err, context.Canceled) && ctx.Err() == nil {
+				continue
+			}
+			return call.res, call.err
+		case <-ctx.Done():
+			c.leave(key, call)
+			return "", ctx.Err()
+		}
+	}
+}
+
+// start sends shared request in background, c.mx should be locked
+func (c *CachedConn) start(key string, q Query, readOnly bool) *cacheCall {
+	// values of context like trace span are kept, but it is cancelled only by leave
+	ctx, cancel := context.WithCancel(context.WithoutCancel(q.Context()))
+	q.SetContext(ctx)
+	call := &cacheCall{done: make(chan struct{}), cancel: cancel}
+	c.inflight[key] = call
+
+	go func() {
+		defer cancel()
+		res, err := c.conn.Exec(q, readOnly)
+		if err == nil {
+			err = errorFromResponse(res)
+		}
+
+		c.mx.Lock()
+		if c.inflight[key] == call {
+			delete(c.inflight, key)
+		}
+		if err == nil {
+			c.put(key, res)
+		}
+		c.mx.Unlock()
+		call.res, call.err = res, err
+		close(call.done)
+	}()
+	return call
+}
+
+// leave is called by caller whose context is done, request is cancelled when nobody waits for it
+func (c *CachedConn) leave(key string, call *cacheCall) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+	}
+	call.cancel()
+}
+
+func (c *CachedConn) GetHost() string {
+	return c.conn.GetHost()
+}
+
+// Location returns time zone of wrapped connection
+func (c *CachedConn) Location() *time.Location {
+	if conn, ok := c.conn.(interface{ Location() *time.Location }); ok {
+		return conn.Location()
+	}
+	return time.UTC
+}
+
+// Len returns amount of cached responses, expired ones are counted until they are evicted
+func (c *CachedConn) Len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.lru.Len()
+}
+
+// Purge removes all cached responses
+func (c *CachedConn) Purge() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
+
+func (c *CachedConn) get(key string) (string, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		return "", false
+	}
+	c.lru.MoveToFront(el)
+	return entry.res, true
+}
+
+func (c *CachedConn) put(key, res string) {
+	size := int64(len(key) + len(res))
+	if size > c.max {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	for c.size+size > c.max {
+		c.remove(c.lru.Back())
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, res: res, expires: time.Now().Add(c.ttl)})
+	c.size += size
+}
+
+func (c *CachedConn) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.items, entry.key)
+	c.size -= int64(len(entry.key) + len(entry.res))
+}
+
+// SkipCache makes CachedConn always send query to server
+func (q *Query) SkipCache() {
+	q.noCache = true
+}
+
+// cacheKey returns key of query, queries which change data or cannot be compared are not cached
+func cacheKey(host string, q Query) (string, bool) {
+	if q.noCache || len(q.externals) > 0 || q.body != nil {
+		return "", false
+	}
+	stmt := normalizeStatement(q.Stmt)
+	if !isReadStatement(stmt) {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(host)
+	b.WriteByte(0)
+	b.WriteString(stmt)
+	for _, arg := range q.args {
+		val, err := marshal(arg)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(0)
+		b.WriteString(val)
+	}
+	if len(q.params) > 0 {
+		params := cloneParams(q.params)
+		params.Del("query_id")
+		b.WriteByte(0)
+		b.WriteString(params.Encode())
+	}
+	return b.String(), true
+}
+
+func isReadStatement(stmt string) bool {
+	word := stmt
+	if pos := strings.IndexAny(stmt, " ("); pos >= 0 {
+		word = stmt[:pos]
+	}
+	switch strings.ToUpper(word) {
+	case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXISTS":
+		return true
+	}
+	return false
+}
+
+// normalizeStatement collapses whitespace outside of quoted strings and identifiers
+func normalizeStatement(stmt string) string {
+	var b strings.Builder
+	var quote byte
+	space := false
+	for i := 0; i < len(stmt); i++ {
+		ch := stmt[i]
+		if quote != 0 {
+			b.WriteByte(ch)
+			if ch == '\\' && i+1 < len(stmt) {
+				i++
+				b.WriteByte(stmt[i])
+			} else if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case ' ', '\t', '\n', '\r':
+			space = true
+			continue
+		case '\'', '"', '`':
+			quote = ch
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteByte(ch)
+	}
+	return strings.TrimSuffix(b.String(), ";")
+}
@@ -0,0 +1,204 @@
+package clickhouse
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	calls    int32
+	duration time.Duration
+}
+
+func (t *countingTransport) Exec(host, params string, q Query, readOnly bool) (string, error) {
+	n := atomic.AddInt32(&t.calls, 1)
+	time.Sleep(t.duration)
+	if strings.Contains(q.Stmt, "missing") {
+		return "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist", nil
+	}
+	return strings.Repeat("x", 10) + string(rune('0'+n)), nil
+}
+
+func TestCachedConn(t *testing.T) {
+	tr := &countingTransport{}
+	cached := NewCachedConn(NewConn(getHost(), tr), CacheOptions{})
+
+	res1, err := cached.Exec(NewQuery("SELECT  *\nFROM t WHERE a = :value:", 1), false)
+	assert.NoError(t, err)
+	res2, err := cached.Exec(NewQuery("SELECT * FROM t WHERE a = :value:;", 1), false)
+	assert.NoError(t, err)
+	assert.Equal(t, res1, res2)
+	assert.Equal(t, int32(1), tr.calls)
+
+	// other arguments, params, literals and hosts are different queries
+	cached.Exec(NewQuery("SELECT * FROM t WHERE a = :value:", 2), false)
+	q := NewQuery("SELECT * FROM t WHERE a = :value:", 1)
+	q.params.Set("max_threads", "1")
+	cached.Exec(q, false)
+	// query_id is ignored
+	q.params.Set("query_id", "other")
+	cached.Exec(q, false)
+	cached.Exec(NewQuery("SELECT 'a  b'"), false)
+	cached.Exec(NewQuery("SELECT 'a b'"), false)
+	NewCachedConn(NewConn("other", tr), CacheOptions{}).Exec(NewQuery("SELECT * FROM t WHERE a = :value:", 1), false)
+	assert.Equal(t, int32(6), tr.calls)
+
+	// writes, opt-out and errors are not cached
+	cached.Exec(NewQuery("INSERT INTO t VALUES (1)"), false)
+	cached.Exec(NewQuery("INSERT INTO t VALUES (1)"), false)
+	q = NewQuery("SELECT now()")
+	q.SkipCache()
+	cached.Exec(q, false)
+	cached.Exec(q, false)
+	_, err = cached.Exec(NewQuery("SELECT * FROM missing"), false)
+	assert.Error(t, err)
+	_, err = cached.Exec(NewQuery("SELECT * FROM missing"), false)
+	assert.Error(t, err)
+	assert.Equal(t, int32(12), tr.calls)
+
+	cached.Purge()
+	assert.Equal(t, 0, cached.Len())
+	cached.Exec(NewQuery("SELECT 'a b'"), false)
+	assert.Equal(t, int32(13), tr.calls)
+}
+
+func TestCachedConn_Eviction(t *testing.T) {
+	tr := &countingTransport{}
+	// each entry takes 38 bytes
+	cached := NewCachedConn(NewConn(getHost(), tr), CacheOptions{TTL: 50 * time.Millisecond, MaxBytes: 80})
+
+	cached.Exec(NewQuery("SELECT 1"), false)
+	cached.Exec(NewQuery("SELECT 2"), false)
+	cached.Exec(NewQuery("SELECT 1"), false)
+	cached.Exec(NewQuery("SELECT 3"), false)
+	assert.Equal(t, 2, cached.Len())
+	assert.Equal(t, int32(3), tr.calls)
+
+	// SELECT 2 is least recently used
+	cached.Exec(NewQuery("SELECT 1"), false)
+	cached.Exec(NewQuery("SELECT 2"), false)
+	assert.Equal(t, int32(4), tr.calls)
+
+	time.Sleep(60 * time.Millisecond)
+	cached.Exec(NewQuery("SELECT 2"), false)
+	assert.Equal(t, int32(5), tr.calls)
+
+	cached = NewCachedConn(NewConn(getHost(), tr), CacheOptions{MaxBytes: 10})
+	cached.Exec(NewQuery("SELECT 1"), false)
+	assert.Equal(t, 0, cached.Len())
+}
+
+func TestCachedConn_Concurrent(t *testing.T) {
+	tr := &countingTransport{duration: 20 * time.Millisecond}
+	cached := NewCachedConn(NewConn(getHost(), tr), CacheOptions{})
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			results[i], err = cached.Exec(NewQuery("SELECT count() FROM t"), false)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), tr.calls)
+	for _, res := range results {
+		assert.Equal(t, results[0], res)
+	}
+
+	iter := NewQuery("SELECT count() FROM t").Iter(cached)
+	var s string
+	assert.True(t, iter.Scan(&s))
+	assert.Equal(t, results[0], s)
+	assert.Equal(t, int32(1), tr.calls)
+}
+
+// blockingTransport answers when release is closed or request context is done
+type blockingTransport struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockingTransport) Exec(host, params string, q Query, readOnly bool) (string, error) {
+	atomic.AddInt32(&t.calls, 1)
+	t.started <- struct{}{}
+	select {
+	case <-t.release:
+		return "1", nil
+	case <-q.Context().Done():
+		return "", q.Context().Err()
+	}
+}
+
+func TestCachedConn_Cancel(t *testing.T) {
+	tr := &blockingTransport{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cached := NewCachedConn(NewConn(getHost(), tr), CacheOptions{})
+	query := func(ctx context.Context) Query {
+		q := NewQuery("SELECT count() FROM t")
+		q.SetContext(ctx)
+		return q
+	}
+
+	// first caller leaves, second one still gets result of shared request
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := cached.Exec(query(firstCtx), false)
+		first <- err
+	}()
+	<-tr.started
+	second := make(chan string, 1)
+	go func() {
+		res, err := cached.Exec(query(context.Background()), false)
+		assert.NoError(t, err)
+		second <- res
+	}()
+	key, _ := cacheKey(cached.GetHost(), query(context.Background()))
+	for {
+		cached.mx.Lock()
+		waiters := cached.inflight[key].waiters
+		cached.mx.Unlock()
+		if waiters == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancelFirst()
+	assert.Equal(t, context.Canceled, <-first)
+	close(tr.release)
+	assert.Equal(t, "1", <-second)
+	assert.Equal(t, int32(1), tr.calls)
+
+	// request is cancelled when nobody waits for it
+	cached.Purge()
+	tr.release = make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cached.Exec(query(ctx), false)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	<-tr.started
+	close(tr.release)
+	res, err := cached.Exec(query(context.Background()), false)
+	<-tr.started
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res)
+	assert.Equal(t, int32(3), tr.calls)
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	assert.Equal(t, "SELECT a, 'x  \\'  y' FROM t", normalizeStatement("\n SELECT  a,\t'x  \\'  y'\nFROM t;"))
+	assert.Equal(t, "SELECT \"a  b\"", normalizeStatement("SELECT   \"a  b\""))
+	assert.True(t, isReadStatement("WITH x AS (SELECT 1) SELECT * FROM x"))
+	assert.True(t, isReadStatement("select 1"))
+	assert.False(t, isReadStatement("ALTER TABLE t DELETE WHERE 1"))
+}
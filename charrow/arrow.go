@@ -0,0 +1,144 @@
+// Package charrow exchanges Apache Arrow record batches with ClickHouse using ArrowStream format
+package charrow
+
+import (
+	"errors"
+	"io"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+const FormatArrowStream = "ArrowStream"
+
+// Reader yields record batches of query result while response is received:
+//
+//	r, err := charrow.Query(conn, clickhouse.NewQuery("SELECT id, price FROM orders"))
+//	defer r.Close()
+//	for r.Next() {
+//		rec := r.Record()
+//	}
+//	err = r.Error()
+type Reader struct {
+	body io.ReadCloser
+	r    *ipc.Reader
+	// exc finds exception which server sends after some batches
+	exc  *clickhouse.ExceptionReader
+	done bool
+}
+
+// Query runs q in ArrowStream format, options are passed to ipc.NewReader
+func Query(conn clickhouse.Connector, q clickhouse.Query, opts ...ipc.Option) (*Reader, error) {
+	q.Stmt += " FORMAT " + FormatArrowStream
+	body, err := q.Stream(conn)
+	if err != nil {
+		return nil, err
+	}
+	exc := clickhouse.NewExceptionReader(body)
+	r, err := ipc.NewReader(exc, opts...)
+	if err != nil {
+		body.Close()
+		if excErr := exc.Exception(); excErr != nil {
+			return nil, excErr
+		}
+		// server sends nothing for empty result
+		if errors.Is(err, io.EOF) {
+			return &Reader{}, nil
+		}
+		return nil, err
+	}
+	return &Reader{body: body, r: r, exc: exc}, nil
+}
+
+// Schema returns schema of result, it is nil for empty result
+func (r *Reader) Schema() *arrow.Schema {
+	if r.r == nil {
+		return nil
+	}
+	return r.r.Schema()
+}
+
+// Next reads next record batch
+func (r *Reader) Next() bool {
+	if r.r == nil || r.done {
+		return false
+	}
+	if !r.r.Next() {
+		r.done = true
+		// exception may follow end of stream marker
+		io.Copy(io.Discard, io.LimitReader(r.exc, 64*1024))
+		return false
+	}
+	return true
+}
+
+// Record returns batch read by last Next call, it is released on next call unless retained
+func (r *Reader) Record() arrow.Record {
+	return r.r.Record()
+}
+
+// Error returns read error or server exception sent after some batches
+func (r *Reader) Error() error {
+	if r.r == nil {
+		return nil
+	}
+	if r.done {
+		if err := r.exc.Exception(); err != nil {
+			return err
+		}
+	}
+	return r.r.Err()
+}
+
+// Close releases reader and response body
+func (r *Reader) Close() error {
+	if r.r == nil {
+		return nil
+	}
+	r.r.Release()
+	r.r = nil
+	return r.body.Close()
+}
+
+// Writer streams record batches into INSERT ... FORMAT ArrowStream request
+type Writer struct {
+	stream clickhouse.InsertWriter
+	w      *ipc.Writer
+}
+
+// Insert starts insert request into columns named as schema fields, options are passed to ipc.NewWriter
+func Insert(conn clickhouse.Connector, table string, schema *arrow.Schema, opts ...ipc.Option) (*Writer, error) {
+	if schema == nil || len(schema.Fields()) == 0 {
+		return nil, errors.New("Schema is empty")
+	}
+	cols := make(clickhouse.Columns, 0, len(schema.Fields()))
+	for _, f := range schema.Fields() {
+		cols = append(cols, f.Name)
+	}
+	stream, err := clickhouse.NewInsertWriter(conn, table, FormatArrowStream, cols)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]ipc.Option{ipc.WithSchema(schema)}, opts...)
+	return &Writer{stream: stream, w: ipc.NewWriter(stream, opts...)}, nil
+}
+
+// Write sends record batch, its schema should match schema of Insert
+func (w *Writer) Write(rec arrow.Record) error {
+	return w.w.Write(rec)
+}
+
+// Close finishes stream and waits for server response
+func (w *Writer) Close() error {
+	err := w.w.Close()
+	if res := w.stream.Close(); res != nil {
+		return res
+	}
+	return err
+}
+
+// Abort breaks request, so server does not commit batches which were already sent
+func (w *Writer) Abort(err error) {
+	w.stream.Abort(err)
+}
@@ -0,0 +1,203 @@
+package charrow
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+// tableServer keeps body of last insert and returns it for selects
+type tableServer struct {
+	insert string
+	query  string
+	data   []byte
+	resp   string
+	// tail is sent after data of select, e.g. exception
+	tail string
+}
+
+func (s *tableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if q := r.URL.Query().Get("query"); strings.HasPrefix(q, "INSERT") {
+		s.insert = q
+		s.data = body
+	} else {
+		s.query = string(body)
+	}
+	if s.resp != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, s.resp)
+		return
+	}
+	if s.query != "" {
+		w.Write(s.data)
+		fmt.Fprint(w, s.tail)
+	}
+}
+
+func TestInsertAndQuery(t *testing.T) {
+	server := &tableServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	conn := clickhouse.NewConn(ts.URL, clickhouse.NewHttpTransport())
+
+	schema, err := ArrowSchema(clickhouse.Schema{{Name: "id", Type: "UInt64"}, {Name: "name", Type: "Nullable(String)"}, {Name: "tags", Type: "Array(String)"}})
+	assert.NoError(t, err)
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer b.Release()
+	b.Field(0).(*array.Uint64Builder).AppendValues([]uint64{1, 2}, nil)
+	b.Field(1).(*array.StringBuilder).AppendValues([]string{"a", ""}, []bool{true, false})
+	tags := b.Field(2).(*array.ListBuilder)
+	tags.Append(true)
+	tags.ValueBuilder().(*array.StringBuilder).AppendValues([]string{"x", "y"}, nil)
+	tags.Append(true)
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	w, err := Insert(conn, "t", schema)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write(rec))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "INSERT INTO t (id,name,tags) FORMAT ArrowStream", server.insert)
+
+	r, err := Query(conn, clickhouse.NewQuery("SELECT * FROM t"))
+	assert.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, "SELECT * FROM t FORMAT ArrowStream", server.query)
+	assert.True(t, r.Schema().Equal(schema))
+	assert.True(t, r.Next())
+	assert.True(t, array.RecordEqual(rec, r.Record()))
+	assert.False(t, r.Next())
+	assert.NoError(t, r.Error())
+
+	// exception after first batch
+	server.tail = "Code: 241. DB::Exception: Memory limit (for query) exceeded\n"
+	r, err = Query(conn, clickhouse.NewQuery("SELECT * FROM t"))
+	assert.NoError(t, err)
+	defer r.Close()
+	for r.Next() {
+	}
+	if assert.Error(t, r.Error()) {
+		assert.Equal(t, 241, r.Error().(*clickhouse.DbError).Code())
+	}
+
+	// server does not finish stream when query fails
+	server.data = server.data[:len(server.data)-8]
+	r, err = Query(conn, clickhouse.NewQuery("SELECT * FROM t"))
+	assert.NoError(t, err)
+	defer r.Close()
+	for r.Next() {
+	}
+	if assert.Error(t, r.Error()) {
+		assert.Equal(t, 241, r.Error().(*clickhouse.DbError).Code())
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	server := &tableServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	conn := clickhouse.NewConn(ts.URL, clickhouse.NewHttpTransport())
+
+	// empty result
+	r, err := Query(conn, clickhouse.NewQuery("SELECT 1 WHERE 0"))
+	assert.NoError(t, err)
+	assert.Nil(t, r.Schema())
+	assert.False(t, r.Next())
+	assert.NoError(t, r.Error())
+	assert.NoError(t, r.Close())
+
+	server.resp = "Code: 60, e.displayText() = DB::Exception: Table default.t doesn't exist"
+	_, err = Query(conn, clickhouse.NewQuery("SELECT * FROM t"))
+	assert.Error(t, err)
+
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Uint8}}, nil)
+	w, err := Insert(conn, "t", schema)
+	assert.NoError(t, err)
+	err = w.Close()
+	assert.Error(t, err)
+	assert.Equal(t, 60, err.(*clickhouse.DbError).Code())
+
+	_, err = Insert(conn, "t", arrow.NewSchema(nil, nil))
+	assert.Error(t, err)
+	_, err = Insert(nil, "t", schema)
+	assert.Error(t, err)
+}
+
+func TestArrowType(t *testing.T) {
+	cases := []struct {
+		ch       string
+		arrow    string
+		nullable bool
+	}{
+		{"UInt64", "uint64", false},
+		{"Nullable(Int32)", "int32", true},
+		{"LowCardinality(Nullable(String))", "utf8", true},
+		{"Bool", "bool", false},
+		{"Enum8('a' = 1)", "int8", false},
+		{"FixedString(3)", "fixed_size_binary[3]", false},
+		{"UUID", "fixed_size_binary[16]", false},
+		{"Date", "uint16", false},
+		{"Date32", "date32", false},
+		{"DateTime('UTC')", "uint32", false},
+		{"DateTime64(3, 'Asia/Tokyo')", "timestamp[ms, tz=Asia/Tokyo]", false},
+		{"DateTime64(9)", "timestamp[ns]", false},
+		{"Decimal(18, 4)", "decimal(18, 4)", false},
+		{"Decimal256(10)", "decimal256(76, 10)", false},
+		{"Array(Nullable(Float64))", "list<item: float64, nullable>", false},
+		{"Tuple(a String, b UInt8)", "struct<a: utf8, b: uint8>", false},
+		{"Tuple(String, UInt8)", "struct<1: utf8, 2: uint8>", false},
+		{"Map(String, UInt16)", "map<utf8, uint16, items_non_nullable>", false},
+		{"Nested(k String, v Int8)", "list<item: struct<k: utf8, v: int8>>", false},
+	}
+	for _, c := range cases {
+		dt, nullable, err := ArrowType(c.ch)
+		if assert.NoError(t, err, c.ch) {
+			assert.Equal(t, c.arrow, dt.String(), c.ch)
+			assert.Equal(t, c.nullable, nullable, c.ch)
+		}
+	}
+
+	_, _, err := ArrowType("Object('json')")
+	assert.Error(t, err)
+	_, err = ArrowSchema(clickhouse.Schema{{Name: "a", Type: "Array("}})
+	assert.Error(t, err)
+}
+
+func TestClickHouseSchema(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "created", Type: &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}},
+		{Name: "price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "attrs", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Float64)},
+		{Name: "pair", Type: arrow.StructOf(arrow.Field{Name: "x", Type: arrow.PrimitiveTypes.Uint8})},
+		{Name: "kind", Type: &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}},
+	}, nil)
+	res, err := ClickHouseSchema(schema)
+	assert.NoError(t, err)
+	assert.Equal(t, clickhouse.Schema{
+		{Name: "id", Type: "Int64"},
+		{Name: "name", Type: "Nullable(String)"},
+		{Name: "created", Type: "DateTime64(6, 'UTC')"},
+		{Name: "price", Type: "Decimal(10, 2)"},
+		{Name: "tags", Type: "Array(Nullable(String))"},
+		{Name: "attrs", Type: "Map(String, Nullable(Float64))"},
+		{Name: "pair", Type: "Tuple(`x` UInt8)"},
+		{Name: "kind", Type: "LowCardinality(String)"},
+	}, res)
+
+	_, err = ClickHouseSchema(arrow.NewSchema([]arrow.Field{{Name: "d", Type: arrow.FixedWidthTypes.Duration_s}}, nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,244 @@
+package charrow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+// ArrowSchema maps ClickHouse columns to Arrow fields of the same types server uses in ArrowStream output
+func ArrowSchema(schema clickhouse.Schema) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(schema))
+	for i, col := range schema {
+		t, err := clickhouse.ParseColumnType(col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		dt, nullable, err := arrowType(t)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		fields[i] = arrow.Field{Name: col.Name, Type: dt, Nullable: nullable}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// ArrowType maps ClickHouse type to Arrow type, nullable is true for Nullable types:
+//
+//   - Date is UInt16 and DateTime is UInt32 as server sends them, DateTime64 is timestamp
+//   - Enum is integer of the same size, LowCardinality is type of its values
+//   - UUID, IPv6, Int128 and other wide integers are fixed size binary
+//   - Array and Nested are lists, Tuple is struct and Map is map
+func ArrowType(chType string) (dt arrow.DataType, nullable bool, err error) {
+	t, err := clickhouse.ParseColumnType(chType)
+	if err != nil {
+		return nil, false, err
+	}
+	return arrowType(t)
+}
+
+func arrowType(t *clickhouse.ColumnType) (arrow.DataType, bool, error) {
+	switch t.Name {
+	case "Nullable":
+		dt, _, err := arrowType(t.Args[0])
+		return dt, true, err
+	case "LowCardinality":
+		return arrowType(t.Args[0])
+	case "Bool":
+		return arrow.FixedWidthTypes.Boolean, false, nil
+	case "Int8", "Enum8":
+		return arrow.PrimitiveTypes.Int8, false, nil
+	case "Int16", "Enum16":
+		return arrow.PrimitiveTypes.Int16, false, nil
+	case "Int32":
+		return arrow.PrimitiveTypes.Int32, false, nil
+	case "Int64":
+		return arrow.PrimitiveTypes.Int64, false, nil
+	case "UInt8":
+		return arrow.PrimitiveTypes.Uint8, false, nil
+	case "UInt16", "Date":
+		return arrow.PrimitiveTypes.Uint16, false, nil
+	case "UInt32", "DateTime", "IPv4":
+		return arrow.PrimitiveTypes.Uint32, false, nil
+	case "UInt64":
+		return arrow.PrimitiveTypes.Uint64, false, nil
+	case "Float32":
+		return arrow.PrimitiveTypes.Float32, false, nil
+	case "Float64":
+		return arrow.PrimitiveTypes.Float64, false, nil
+	case "String":
+		return arrow.BinaryTypes.String, false, nil
+	case "FixedString":
+		return &arrow.FixedSizeBinaryType{ByteWidth: t.Size}, false, nil
+	case "UUID", "IPv6", "Int128", "UInt128":
+		return &arrow.FixedSizeBinaryType{ByteWidth: 16}, false, nil
+	case "Int256", "UInt256":
+		return &arrow.FixedSizeBinaryType{ByteWidth: 32}, false, nil
+	case "Date32":
+		return arrow.FixedWidthTypes.Date32, false, nil
+	case "DateTime64":
+		ts := &arrow.TimestampType{Unit: timeUnit(t.Precision)}
+		if t.Location != nil {
+			ts.TimeZone = t.Location.String()
+		}
+		return ts, false, nil
+	case "Decimal":
+		if t.Precision > 38 {
+			return &arrow.Decimal256Type{Precision: int32(t.Precision), Scale: int32(t.Scale)}, false, nil
+		}
+		return &arrow.Decimal128Type{Precision: int32(t.Precision), Scale: int32(t.Scale)}, false, nil
+	case "Array":
+		elem, nullable, err := arrowType(t.Args[0])
+		if err != nil {
+			return nil, false, err
+		}
+		return arrow.ListOfField(arrow.Field{Name: "item", Type: elem, Nullable: nullable}), false, nil
+	case "Nested":
+		st, _, err := arrowType(&clickhouse.ColumnType{Name: "Tuple", Args: t.Args, Names: t.Names})
+		if err != nil {
+			return nil, false, err
+		}
+		return arrow.ListOfField(arrow.Field{Name: "item", Type: st}), false, nil
+	case "Tuple":
+		fields := make([]arrow.Field, len(t.Args))
+		for i, arg := range t.Args {
+			dt, nullable, err := arrowType(arg)
+			if err != nil {
+				return nil, false, err
+			}
+			// unnamed elements are numbered from 1 as in tupleElement
+			name := strconv.Itoa(i + 1)
+			if len(t.Names) > i && t.Names[i] != "" {
+				name = t.Names[i]
+			}
+			fields[i] = arrow.Field{Name: name, Type: dt, Nullable: nullable}
+		}
+		return arrow.StructOf(fields...), false, nil
+	case "Map":
+		key, _, err := arrowType(t.Args[0])
+		if err != nil {
+			return nil, false, err
+		}
+		value, nullable, err := arrowType(t.Args[1])
+		if err != nil {
+			return nil, false, err
+		}
+		m := arrow.MapOf(key, value)
+		m.SetItemNullable(nullable)
+		return m, false, nil
+	}
+	return nil, false, fmt.Errorf("Type %s is not supported by ArrowStream format", t)
+}
+
+func timeUnit(precision int) arrow.TimeUnit {
+	switch {
+	case precision == 0:
+		return arrow.Second
+	case precision <= 3:
+		return arrow.Millisecond
+	case precision <= 6:
+		return arrow.Microsecond
+	}
+	return arrow.Nanosecond
+}
+
+// ClickHouseSchema maps Arrow fields to ClickHouse columns, e.g. to create table for record batches
+func ClickHouseSchema(schema *arrow.Schema) (clickhouse.Schema, error) {
+	res := make(clickhouse.Schema, 0, len(schema.Fields()))
+	for _, f := range schema.Fields() {
+		typ, err := ClickHouseType(f.Type, f.Nullable)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		res = append(res, clickhouse.ColumnSchema{Name: f.Name, Type: typ})
+	}
+	return res, nil
+}
+
+// ClickHouseType maps Arrow type to ClickHouse type, nullable is ignored for nested types which cannot be Nullable
+func ClickHouseType(dt arrow.DataType, nullable bool) (string, error) {
+	var typ string
+	switch t := dt.(type) {
+	case *arrow.BooleanType:
+		typ = "Bool"
+	case *arrow.Int8Type:
+		typ = "Int8"
+	case *arrow.Int16Type:
+		typ = "Int16"
+	case *arrow.Int32Type:
+		typ = "Int32"
+	case *arrow.Int64Type:
+		typ = "Int64"
+	case *arrow.Uint8Type:
+		typ = "UInt8"
+	case *arrow.Uint16Type:
+		typ = "UInt16"
+	case *arrow.Uint32Type:
+		typ = "UInt32"
+	case *arrow.Uint64Type:
+		typ = "UInt64"
+	case *arrow.Float16Type, *arrow.Float32Type:
+		typ = "Float32"
+	case *arrow.Float64Type:
+		typ = "Float64"
+	case *arrow.StringType, *arrow.LargeStringType, *arrow.BinaryType, *arrow.LargeBinaryType:
+		typ = "String"
+	case *arrow.FixedSizeBinaryType:
+		typ = fmt.Sprintf("FixedString(%d)", t.ByteWidth)
+	case *arrow.Date32Type:
+		typ = "Date32"
+	case *arrow.Date64Type:
+		typ = "DateTime64(3)"
+	case *arrow.TimestampType:
+		typ = fmt.Sprintf("DateTime64(%d", 3*int(t.Unit))
+		if t.TimeZone != "" {
+			typ += ", '" + t.TimeZone + "'"
+		}
+		typ += ")"
+	case *arrow.Decimal128Type:
+		typ = fmt.Sprintf("Decimal(%d, %d)", t.Precision, t.Scale)
+	case *arrow.Decimal256Type:
+		typ = fmt.Sprintf("Decimal(%d, %d)", t.Precision, t.Scale)
+	case *arrow.DictionaryType:
+		inner, err := ClickHouseType(t.ValueType, nullable)
+		if err != nil {
+			return "", err
+		}
+		return "LowCardinality(" + inner + ")", nil
+	case *arrow.MapType:
+		key, err := ClickHouseType(t.KeyType(), false)
+		if err != nil {
+			return "", err
+		}
+		value, err := ClickHouseType(t.ItemType(), t.ItemField().Nullable)
+		if err != nil {
+			return "", err
+		}
+		return "Map(" + key + ", " + value + ")", nil
+	case arrow.ListLikeType:
+		elem, err := ClickHouseType(t.Elem(), t.ElemField().Nullable)
+		if err != nil {
+			return "", err
+		}
+		return "Array(" + elem + ")", nil
+	case *arrow.StructType:
+		elems := make([]string, len(t.Fields()))
+		for i, f := range t.Fields() {
+			elem, err := ClickHouseType(f.Type, f.Nullable)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = "`" + strings.ReplaceAll(f.Name, "`", "\\`") + "` " + elem
+		}
+		return "Tuple(" + strings.Join(elems, ", ") + ")", nil
+	default:
+		return "", fmt.Errorf("Arrow type %s is not supported", dt)
+	}
+	if nullable {
+		return "Nullable(" + typ + ")", nil
+	}
+	return typ, nil
+}
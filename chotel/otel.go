@@ -0,0 +1,75 @@
+// Package chotel adapts OpenTelemetry tracer for clickhouse.Trace interceptor:
+//
+//	conn.Use(clickhouse.Trace(chotel.NewTracer(otel.Tracer("clickhouse")), clickhouse.TracingOptions{}))
+package chotel
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	clickhouse "github.com/undiabler/clickhouse-driver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns clickhouse.Tracer which starts client spans with t
+func NewTracer(t trace.Tracer) clickhouse.Tracer {
+	return tracer{tracer: t}
+}
+
+func (t tracer) Start(ctx context.Context, name string) (context.Context, clickhouse.Span) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttribute(key string, value interface{}) {
+	s.span.SetAttributes(attributeOf(key, value))
+}
+
+// TraceParent formats span context as traceparent header of W3C Trace Context
+func (s span) TraceParent() string {
+	sc := s.span.SpanContext()
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func attributeOf(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return attribute.String(key, fmt.Sprint(v))
+		}
+		return attribute.Int64(key, int64(v))
+	case bool:
+		return attribute.Bool(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	}
+	return attribute.String(key, fmt.Sprint(value))
+}
@@ -0,0 +1,72 @@
+package chotel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		w.Header().Set("X-ClickHouse-Summary", `{"read_rows":"3","read_bytes":"24"}`)
+		io.WriteString(w, "1\n")
+	}))
+	defer server.Close()
+	conn := clickhouse.NewConn(server.URL, clickhouse.NewHttpTransport())
+	conn.Use(clickhouse.Trace(NewTracer(provider.Tracer("test")), clickhouse.TracingOptions{}))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	q := clickhouse.NewQuery("SELECT 1")
+	q.SetContext(ctx)
+	assert.NoError(t, q.Exec(conn))
+	parent.End()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		s := spans[0]
+		assert.Equal(t, "clickhouse.exec", s.Name())
+		assert.Equal(t, trace.SpanKindClient, s.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), s.Parent().SpanID())
+		assert.Contains(t, s.Attributes(), attribute.String(clickhouse.AttrStatement, "SELECT 1"))
+		assert.Contains(t, s.Attributes(), attribute.Int64(clickhouse.AttrReadRows, 3))
+		assert.Equal(t, "00-"+s.SpanContext().TraceID().String()+"-"+s.SpanContext().SpanID().String()+"-01", traceParent)
+	}
+}
+
+func TestSpan_End(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, s := NewTracer(provider.Tracer("test")).Start(context.Background(), "clickhouse.exec")
+	s.SetAttribute(clickhouse.AttrErrorCode, int64(60))
+	s.SetAttribute("big", uint64(1<<63))
+	s.End(errors.New("Table does not exist"))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Contains(t, spans[0].Attributes(), attribute.Int64(clickhouse.AttrErrorCode, 60))
+		assert.Contains(t, spans[0].Attributes(), attribute.String("big", "9223372036854775808"))
+		assert.Len(t, spans[0].Events(), 1)
+	}
+
+	_, s = NewTracer(noop.NewTracerProvider().Tracer("test")).Start(context.Background(), "clickhouse.exec")
+	assert.Empty(t, s.TraceParent())
+}
@@ -0,0 +1,160 @@
+// Package chprom collects metrics of clickhouse requests and cluster checks for Prometheus:
+//
+//	metrics := chprom.NewCollector("")
+//	prometheus.MustRegister(metrics)
+//	conn := clickhouse.NewConn("localhost:8123", clickhouse.NewHttpTransport().WithMetrics(metrics))
+//	cluster.SetMetrics(metrics)
+package chprom
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+// Collector implements clickhouse.MetricsCollector, clickhouse.QueueMetrics and prometheus.Collector
+type Collector struct {
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	sent        *prometheus.CounterVec
+	received    *prometheus.CounterVec
+	inFlight    *prometheus.GaugeVec
+	activeNodes prometheus.Gauge
+	nodes       prometheus.Gauge
+	pingLast    *prometheus.GaugeVec
+	pingAvg     *prometheus.GaugeVec
+	pingErrors  *prometheus.CounterVec
+	queueWait   *prometheus.HistogramVec
+	queueErrors *prometheus.CounterVec
+}
+
+// NewCollector creates collector, metric names start with namespace, default is "clickhouse"
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "clickhouse"
+	}
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "requests_total", Help: "Amount of finished requests.",
+		}, []string{"host", "kind", "statement"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "request_duration_seconds", Help: "Time of requests until response is read.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"host", "kind", "statement"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "request_errors_total", Help: "Amount of failed requests by server exception code.",
+		}, []string{"host", "kind", "statement", "code"}),
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "sent_bytes_total", Help: "Size of request bodies.",
+		}, []string{"host"}),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "received_bytes_total", Help: "Size of response bodies.",
+		}, []string{"host"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "requests_in_flight", Help: "Amount of requests waiting for response or being read.",
+		}, []string{"host"}),
+		activeNodes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "cluster_active_nodes", Help: "Amount of connections passed last cluster check.",
+		}),
+		nodes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "cluster_nodes", Help: "Amount of cluster connections.",
+		}),
+		pingLast: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "node_ping_seconds", Help: "Time of last successful ping.",
+		}, []string{"host"}),
+		pingAvg: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "node_ping_avg_seconds", Help: "Average time of successful pings.",
+		}, []string{"host"}),
+		pingErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "node_ping_errors_total", Help: "Amount of failed pings.",
+		}, []string{"host"}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "queue_wait_seconds", Help: "Time of waiting for limiter pool slot.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"pool"}),
+		queueErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "queue_errors_total", Help: "Amount of requests which did not get limiter pool slot.",
+		}, []string{"pool"}),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requests, c.duration, c.errors, c.sent, c.received, c.inFlight,
+		c.activeNodes, c.nodes, c.pingLast, c.pingAvg, c.pingErrors, c.queueWait, c.queueErrors,
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+func (c *Collector) RequestStarted(host, kind, statement string) {
+	c.inFlight.WithLabelValues(hostLabel(host)).Inc()
+}
+
+func (c *Collector) RequestFinished(host, kind, statement string, elapsed time.Duration, sent, received int64, err error) {
+	host = hostLabel(host)
+	c.inFlight.WithLabelValues(host).Dec()
+	c.requests.WithLabelValues(host, kind, statement).Inc()
+	c.duration.WithLabelValues(host, kind, statement).Observe(elapsed.Seconds())
+	c.sent.WithLabelValues(host).Add(float64(sent))
+	c.received.WithLabelValues(host).Add(float64(received))
+	if err != nil {
+		c.errors.WithLabelValues(host, kind, statement, errorCode(err)).Inc()
+	}
+}
+
+func (c *Collector) NodeChecked(host string, last, avg time.Duration, err error) {
+	host = hostLabel(host)
+	if err != nil {
+		c.pingErrors.WithLabelValues(host).Inc()
+		return
+	}
+	c.pingLast.WithLabelValues(host).Set(last.Seconds())
+	c.pingAvg.WithLabelValues(host).Set(avg.Seconds())
+}
+
+func (c *Collector) ClusterChecked(active, total int) {
+	c.activeNodes.Set(float64(active))
+	c.nodes.Set(float64(total))
+}
+
+func (c *Collector) RequestQueued(pool string, wait time.Duration, err error) {
+	c.queueWait.WithLabelValues(pool).Observe(wait.Seconds())
+	if err != nil {
+		c.queueErrors.WithLabelValues(pool).Inc()
+	}
+}
+
+// errorCode returns code of server exception, other errors are network or protocol errors
+func errorCode(err error) string {
+	var dbErr *clickhouse.DbError
+	if errors.As(err, &dbErr) {
+		return strconv.Itoa(dbErr.Code())
+	}
+	return "other"
+}
+
+// hostLabel strips scheme and path of connection host
+func hostLabel(host string) string {
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return host
+}
@@ -0,0 +1,73 @@
+package chprom
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+func TestCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist")
+			return
+		}
+		io.WriteString(w, "1\n")
+	}))
+	defer server.Close()
+	c := NewCollector("")
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	conn := clickhouse.NewConn(server.URL, clickhouse.NewHttpTransport().WithMetrics(c))
+	host := hostLabel(conn.Host)
+	assert.NoError(t, clickhouse.NewQuery("SELECT 1").Exec(conn))
+	assert.Error(t, clickhouse.NewQuery("SELECT * FROM missing").Exec(conn))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(c.requests.WithLabelValues(host, clickhouse.CallExec, "SELECT")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.errors.WithLabelValues(host, clickhouse.CallExec, "SELECT", "60")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.inFlight.WithLabelValues(host)))
+	assert.Equal(t, 8.0+21.0, testutil.ToFloat64(c.sent.WithLabelValues(host)))
+	assert.Equal(t, 1, testutil.CollectAndCount(c.duration))
+
+	cl := clickhouse.NewCluster(conn, clickhouse.NewConn("http://127.0.0.1:1", clickhouse.NewHttpTransport()))
+	cl.SetMetrics(c)
+	cl.Check()
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.activeNodes))
+	assert.Equal(t, 2.0, testutil.ToFloat64(c.nodes))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.pingErrors.WithLabelValues("127.0.0.1:1")))
+	assert.True(t, testutil.ToFloat64(c.pingAvg.WithLabelValues(host)) > 0)
+
+	n, err := testutil.GatherAndCount(registry, "clickhouse_requests_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestCollector_Labels(t *testing.T) {
+	assert.Equal(t, "host1:8123", hostLabel("http://host1:8123/"))
+	assert.Equal(t, "host1", hostLabel("host1"))
+	assert.Equal(t, "other", errorCode(errors.New("Connection timeout")))
+
+	c := NewCollector("ch")
+	c.RequestStarted("http://host1/", clickhouse.CallStream, "SELECT")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.inFlight.WithLabelValues("host1")))
+	c.RequestFinished("http://host1/", clickhouse.CallStream, "SELECT", time.Second, 0, 10, nil)
+	assert.Equal(t, 10.0, testutil.ToFloat64(c.received.WithLabelValues("host1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.requests.WithLabelValues("host1", clickhouse.CallStream, "SELECT")))
+
+	c.RequestQueued("reporting", time.Second, nil)
+	c.RequestQueued("reporting", time.Second, clickhouse.ErrQueueTimeout)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.queueErrors.WithLabelValues("reporting")))
+	assert.Equal(t, 1, testutil.CollectAndCount(c.queueWait))
+}
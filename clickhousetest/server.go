@@ -0,0 +1,373 @@
+// Package clickhousetest provides fake ClickHouse HTTP server for unit tests:
+//
+//	srv := clickhousetest.NewServer()
+//	defer srv.Close()
+//	srv.On("SELECT count() FROM clicks").ReplyRows(clickhouse.Columns{"count()"}, clickhouse.Row{10})
+//	srv.OnMatch(`^INSERT INTO clicks`)
+//
+//	conn := srv.Conn()
+//	// code under test
+//	assert.Len(t, srv.Requests(), 2)
+package clickhousetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+// Request is query received by server
+type Request struct {
+	// Query is statement without data of insert
+	Query string
+	// Format is name from FORMAT clause of statement
+	Format string
+	// Params are settings and other url params except query
+	Params url.Values
+	// Body is insert data sent after statement
+	Body []byte
+	// Externals are data of external tables by name
+	Externals map[string][]byte
+	Header    http.Header
+}
+
+// Server is fake ClickHouse server, responses are chosen by first registered matching rule.
+// Ping query SELECT 1 is answered by default, queries without rule get exception with code 48
+type Server struct {
+	URL string
+
+	server   *httptest.Server
+	mx       sync.Mutex
+	rules    []*Response
+	requests []Request
+}
+
+// NewServer starts server, it is stopped by Close
+func NewServer() *Server {
+	s := &Server{}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close stops server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Conn returns connection to server
+func (s *Server) Conn() *clickhouse.Conn {
+	return clickhouse.NewConn(s.URL, clickhouse.NewHttpTransport())
+}
+
+// On registers response for statement, whitespace and FORMAT clause are not compared
+func (s *Server) On(stmt string) *Response {
+	norm, _ := splitFormat(stmt)
+	return s.add(func(q string) bool { return q == norm })
+}
+
+// OnMatch registers response for statements matching regular expression, FORMAT clause is cut from statement.
+// It panics if pattern cannot be compiled
+func (s *Server) OnMatch(pattern string) *Response {
+	re := regexp.MustCompile(pattern)
+	return s.add(re.MatchString)
+}
+
+func (s *Server) add(match func(string) bool) *Response {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	r := &Response{match: match, status: http.StatusOK}
+	s.rules = append(s.rules, r)
+	return r
+}
+
+// Requests returns copy of received requests
+func (s *Server) Requests() []Request {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset removes registered responses and received requests
+func (s *Server) Reset() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rules = nil
+	s.requests = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := readRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeException(w, 27, err.Error())
+		return
+	}
+	stmt, format := splitFormat(req.Query)
+	req.Format = format
+
+	s.mx.Lock()
+	s.requests = append(s.requests, req)
+	var res *Response
+	for _, rule := range s.rules {
+		if rule.match(stmt) {
+			res = rule
+			break
+		}
+	}
+	s.mx.Unlock()
+
+	if res == nil {
+		if stmt != "SELECT 1" {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeException(w, 48, "clickhousetest: no response for query "+req.Query)
+			return
+		}
+		res = &Response{status: http.StatusOK, body: "1\n"}
+	}
+	res.write(w, r, format)
+}
+
+// readRequest gets statement from url or body like server does
+func readRequest(r *http.Request) (Request, error) {
+	params := r.URL.Query()
+	req := Request{Query: params.Get("query"), Header: r.Header.Clone()}
+	params.Del("query")
+	req.Params = params
+
+	mediaType, mediaParams, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		req.Externals = make(map[string][]byte)
+		mr := multipart.NewReader(r.Body, mediaParams["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return req, err
+			}
+			data, err := io.ReadAll(part)
+			if err != nil {
+				return req, err
+			}
+			req.Externals[part.FormName()] = data
+		}
+		return req, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return req, err
+	}
+	if req.Query != "" {
+		req.Body = body
+		return req, nil
+	}
+	req.Query, req.Body = splitInsertData(string(body))
+	return req, nil
+}
+
+// splitInsertData separates data of INSERT ... FORMAT statement sent in body
+func splitInsertData(body string) (string, []byte) {
+	upper := strings.ToUpper(body)
+	if !strings.HasPrefix(strings.TrimSpace(upper), "INSERT") {
+		return body, nil
+	}
+	pos := strings.Index(upper, " FORMAT ")
+	if pos < 0 {
+		return body, nil
+	}
+	end := strings.IndexByte(body[pos:], '\n')
+	if end < 0 {
+		return body, nil
+	}
+	return body[:pos+end], []byte(body[pos+end+1:])
+}
+
+var formatRe = regexp.MustCompile(`(?i)\s+FORMAT\s+(\w+)\s*;?\s*$`)
+
+// splitFormat returns normalized statement without FORMAT clause and format name
+func splitFormat(stmt string) (string, string) {
+	if m := formatRe.FindStringSubmatchIndex(stmt); m != nil {
+		return normalize(stmt[:m[0]]), stmt[m[2]:m[3]]
+	}
+	return normalize(stmt), ""
+}
+
+func normalize(stmt string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(stmt), " "), ";")
+}
+
+func writeException(w io.Writer, code int, msg string) {
+	fmt.Fprintf(w, "Code: %d, e.displayText() = DB::Exception: %s\n", code, msg)
+}
+
+// Response is reply to matching queries, it is configured by chained calls
+type Response struct {
+	match  func(string) bool
+	status int
+	header http.Header
+	body   string
+	rows   []clickhouse.Row
+	cols   clickhouse.Columns
+	delay  time.Duration
+	// exception is sent after body, as server does when query fails while result is sent
+	exception *exception
+}
+
+type exception struct {
+	code int
+	msg  string
+}
+
+// Reply sets raw response body
+func (r *Response) Reply(body string) *Response {
+	r.body = body
+	return r
+}
+
+// ReplyRows sets result rows, they are formatted as JSON for FORMAT JSON, as JSONEachRow for FORMAT JSONEachRow
+// and as TabSeparated otherwise
+func (r *Response) ReplyRows(cols clickhouse.Columns, rows ...clickhouse.Row) *Response {
+	r.cols, r.rows = cols, rows
+	return r
+}
+
+// Error makes server exception response with status 500
+func (r *Response) Error(code int, msg string) *Response {
+	r.status = http.StatusInternalServerError
+	r.exception = &exception{code, msg}
+	return r
+}
+
+// Status sets http status of response, e.g. 503 for overloaded server
+func (r *Response) Status(status int) *Response {
+	r.status = status
+	return r
+}
+
+// Header sets response header, e.g. X-ClickHouse-Summary
+func (r *Response) Header(name, value string) *Response {
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	r.header.Set(name, value)
+	return r
+}
+
+// Delay makes server wait before response, waiting is stopped when client cancels request
+func (r *Response) Delay(d time.Duration) *Response {
+	r.delay = d
+	return r
+}
+
+// StreamError sends exception after rows with status 200, as server does when error happens while result is sent
+func (r *Response) StreamError(code int, msg string) *Response {
+	r.status = http.StatusOK
+	r.exception = &exception{code, msg}
+	return r
+}
+
+func (r *Response) write(w http.ResponseWriter, req *http.Request, format string) {
+	if r.delay > 0 {
+		select {
+		case <-time.After(r.delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.status)
+
+	if r.status == http.StatusOK || r.exception == nil {
+		io.WriteString(w, r.body)
+		if r.cols != nil {
+			w.Write(formatRows(format, r.cols, r.rows))
+		}
+	}
+	if r.exception != nil {
+		if f, ok := w.(http.Flusher); ok && r.status == http.StatusOK {
+			f.Flush()
+		}
+		writeException(w, r.exception.code, r.exception.msg)
+	}
+}
+
+func formatRows(format string, cols clickhouse.Columns, rows []clickhouse.Row) []byte {
+	buf := &bytes.Buffer{}
+	switch strings.ToUpper(format) {
+	case "JSON":
+		meta := make([]map[string]string, len(cols))
+		for i, col := range cols {
+			meta[i] = map[string]string{"name": col}
+		}
+		data := make([]map[string]interface{}, len(rows))
+		for i, row := range rows {
+			data[i] = rowObject(cols, row)
+		}
+		json.NewEncoder(buf).Encode(map[string]interface{}{"meta": meta, "data": data, "rows": len(rows)})
+	case "JSONEACHROW":
+		enc := json.NewEncoder(buf)
+		for _, row := range rows {
+			enc.Encode(rowObject(cols, row))
+		}
+	default:
+		for _, row := range rows {
+			for i, v := range row {
+				if i > 0 {
+					buf.WriteByte('\t')
+				}
+				buf.WriteString(tsvValue(v))
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func rowObject(cols clickhouse.Columns, row clickhouse.Row) map[string]interface{} {
+	obj := make(map[string]interface{}, len(cols))
+	for i, col := range cols {
+		if i >= len(row) {
+			break
+		}
+		if t, ok := row[i].(time.Time); ok {
+			obj[col] = t.Format("2006-01-02 15:04:05")
+		} else {
+			obj[col] = row[i]
+		}
+	}
+	return obj
+}
+
+func tsvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "\\N"
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case []byte:
+		return clickhouse.EscapeTSV(string(v))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return clickhouse.EscapeTSV(fmt.Sprint(v))
+}
@@ -0,0 +1,178 @@
+package clickhousetest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clickhouse "github.com/undiabler/clickhouse-driver"
+)
+
+func TestServer_Rows(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.On("SELECT id, name FROM users").ReplyRows(clickhouse.Columns{"id", "name"},
+		clickhouse.Row{1, "a\tb"}, clickhouse.Row{2, nil})
+	conn := srv.Conn()
+
+	assert.NoError(t, conn.Ping())
+
+	iter := clickhouse.NewQuery("SELECT id,  name\nFROM users").Iter(conn)
+	var id int
+	var name string
+	assert.True(t, iter.Scan(&id, &name))
+	assert.Equal(t, 1, id)
+	assert.Equal(t, "a\tb", name)
+	assert.NoError(t, iter.Error())
+
+	var users []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	assert.NoError(t, clickhouse.NewQuery("SELECT id, name FROM users").ExecScan(conn, &users))
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, "a\tb", users[0].Name)
+		assert.Equal(t, 2, users[1].ID)
+	}
+
+	rows := clickhouse.NewQuery("SELECT id, name FROM users").JSONEachRowIter(conn)
+	var m map[string]interface{}
+	assert.True(t, rows.Scan(&m))
+	assert.Equal(t, "a\tb", m["name"])
+
+	srv.On("SELECT count() FROM users FORMAT JSON").Reply(`{"data":[{"count()":2}]}`)
+	var counts []struct {
+		Count int `json:"count()"`
+	}
+	assert.NoError(t, clickhouse.NewQuery("SELECT count() FROM users").ExecScan(conn, &counts))
+	if assert.Len(t, counts, 1) {
+		assert.Equal(t, 2, counts[0].Count)
+	}
+
+	err := clickhouse.NewQuery("SELECT * FROM unknown").Exec(conn)
+	if assert.Error(t, err) {
+		assert.Equal(t, 48, err.(*clickhouse.DbError).Code())
+	}
+}
+
+func TestServer_Requests(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.OnMatch(`^INSERT INTO clicks`)
+	conn := clickhouse.NewAuthConn(srv.URL, clickhouse.NewHttpTransport(), "user", "pass")
+
+	assert.NoError(t, clickhouse.NewQuery("INSERT INTO clicks FORMAT TabSeparated\n1\t2\n").Exec(conn))
+	assert.NoError(t, conn.InsertFrom(context.Background(), "clicks", "CSV", strings.NewReader("1,2\n")))
+	q := clickhouse.NewQuery("INSERT INTO clicks SELECT * FROM ext")
+	q.AddExternal("ext", "a UInt8", []byte("1\n"))
+	assert.NoError(t, q.Exec(conn))
+
+	reqs := srv.Requests()
+	if assert.Len(t, reqs, 3) {
+		assert.Equal(t, "INSERT INTO clicks FORMAT TabSeparated", reqs[0].Query)
+		assert.Equal(t, "TabSeparated", reqs[0].Format)
+		assert.Equal(t, "1\t2\n", string(reqs[0].Body))
+		assert.Equal(t, "pass", reqs[0].Params.Get("password"))
+		assert.Equal(t, "CSV", reqs[1].Format)
+		assert.Equal(t, "1,2\n", string(reqs[1].Body))
+		assert.Equal(t, "1\n", string(reqs[2].Externals["ext"]))
+		assert.Equal(t, "a UInt8", reqs[2].Params.Get("ext_structure"))
+	}
+
+	srv.Reset()
+	assert.Empty(t, srv.Requests())
+	assert.Error(t, clickhouse.NewQuery("INSERT INTO clicks VALUES (1, 2)").Exec(conn))
+}
+
+func TestServer_Errors(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.On("SELECT * FROM missing").Error(60, "Table default.missing doesn't exist")
+	srv.On("SELECT 503").Status(http.StatusServiceUnavailable).Reply("overloaded")
+	srv.On("SELECT sleep(1)").Delay(time.Second)
+	srv.On("SELECT big").ReplyRows(clickhouse.Columns{"a"}, clickhouse.Row{1}).StreamError(241, "Memory limit exceeded")
+	srv.On("SELECT summary").Header("X-ClickHouse-Summary", `{"read_rows":"5"}`).Reply("1\n")
+	conn := srv.Conn()
+
+	err := clickhouse.NewQuery("SELECT * FROM missing").Exec(conn)
+	if assert.Error(t, err) {
+		assert.Equal(t, 60, err.(*clickhouse.DbError).Code())
+	}
+
+	_, err = clickhouse.NewQuery("SELECT 503").Stream(conn)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "503")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	q := clickhouse.NewQuery("SELECT sleep(1)")
+	q.SetContext(ctx)
+	start := time.Now()
+	assert.Error(t, q.Exec(conn))
+	assert.True(t, time.Since(start) < time.Second)
+
+	iter := clickhouse.NewQuery("SELECT big").JSONEachRowIter(conn)
+	var m map[string]interface{}
+	assert.True(t, iter.Scan(&m))
+	assert.False(t, iter.Scan(&m))
+	if assert.Error(t, iter.Error()) {
+		assert.Equal(t, 241, iter.Error().(*clickhouse.DbError).Code())
+	}
+
+	var summary clickhouse.Summary
+	conn.Use(func(next clickhouse.Handler) clickhouse.Handler {
+		return func(call *clickhouse.Call) (io.ReadCloser, error) {
+			body, err := next(call)
+			summary, _ = call.Summary()
+			return body, err
+		}
+	})
+	assert.NoError(t, clickhouse.NewQuery("SELECT summary").Exec(conn))
+	assert.Equal(t, uint64(5), summary.ReadRows)
+}
+
+func TestServer_RowsEscaping(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	value := "it's\r\x00\b\f\\\t\n"
+	srv.On("SELECT s FROM t").ReplyRows(clickhouse.Columns{"s"}, clickhouse.Row{value}, clickhouse.Row{[]byte(value)})
+
+	// special characters are escaped like server does it
+	resp, err := srv.Conn().Exec(clickhouse.NewQuery("SELECT s FROM t FORMAT TabSeparated"), false)
+	assert.NoError(t, err)
+	escaped := clickhouse.EscapeTSV(value)
+	assert.Equal(t, escaped+"\n"+escaped+"\n", resp)
+	assert.NotContains(t, resp, "\r")
+
+	iter := clickhouse.NewQuery("SELECT s FROM t").Iter(srv.Conn())
+	var s string
+	assert.True(t, iter.Scan(&s))
+	assert.Equal(t, value, s)
+	assert.True(t, iter.Scan(&s))
+	assert.Equal(t, value, s)
+	assert.False(t, iter.Scan(&s))
+	assert.NoError(t, iter.Error())
+}
+
+func TestServer_ExportParquetStreamError(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.On("SELECT * FROM events").Reply("PAR1\x15\x00").StreamError(241, "Memory limit exceeded")
+	srv.On("SELECT * FROM small").Reply("PAR1 Code: 1 PAR1")
+
+	buf := &bytes.Buffer{}
+	n, err := srv.Conn().ExportParquet(context.Background(), clickhouse.NewQuery("SELECT * FROM events"), buf)
+	if assert.Error(t, err) {
+		assert.Equal(t, 241, err.(*clickhouse.DbError).Code())
+	}
+	assert.Equal(t, int64(buf.Len()), n)
+
+	_, err = srv.Conn().ExportParquet(context.Background(), clickhouse.NewQuery("SELECT * FROM small"), io.Discard)
+	assert.NoError(t, err)
+}
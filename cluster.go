@@ -1,6 +1,8 @@
 package clickhouse
 
 import (
+	"fmt"
+	"io"
 	"math/rand"
 	"sync"
 	"time"
@@ -36,6 +38,24 @@ func (p *pingStats) Avg() int64 {
 	return p.avg
 }
 
+// NodeInfo is cluster state of connection passed to interceptors added by Cluster.Use
+type NodeInfo struct {
+	Cluster *Cluster
+	// Active is true if node passed last Check
+	Active bool
+	// AvgPing is average time of successful pings
+	AvgPing time.Duration
+	// PingErrors is amount of failed pings
+	PingErrors int
+}
+
+// Balancers of Cluster.NextConn
+const (
+	BalancerRandom     = "random"
+	BalancerRoundRobin = "round_robin"
+	BalancerFastest    = "fastest"
+)
+
 // PingErrorFunc callback function, call whenever ping failed
 type PingErrorFunc func(*Conn)
 
@@ -43,11 +63,16 @@ type PingErrorFunc func(*Conn)
 type Cluster struct {
 	conn map[*Conn]*pingStats
 
-	mx     sync.Mutex
-	active []*Conn
+	mx       sync.Mutex
+	active   []*Conn
+	balancer string
+	next     int
 
-	onFail PingErrorFunc
-	onDown func()
+	onFail  PingErrorFunc
+	onDown  func()
+	metrics MetricsCollector
+	// nodeInfoUsed is set when node interceptor is added to connections by first Use
+	nodeInfoUsed bool
 }
 
 // NewCluster create cluster from connections
@@ -89,6 +114,50 @@ func (c *Cluster) ActiveConn() *Conn {
 	return c.active[rand.Intn(l)]
 }
 
+// SetBalancer sets how NextConn chooses active connection, default is BalancerRandom
+func (c *Cluster) SetBalancer(balancer string) error {
+	if err := checkBalancer(balancer); err != nil {
+		return err
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.balancer = balancer
+	return nil
+}
+
+func checkBalancer(balancer string) error {
+	switch balancer {
+	case BalancerRandom, BalancerRoundRobin, BalancerFastest:
+		return nil
+	}
+	return fmt.Errorf("Unknown balancer %q", balancer)
+}
+
+// NextConn return active connection chosen by cluster balancer
+func (c *Cluster) NextConn() *Conn {
+	c.mx.Lock()
+	balancer := c.balancer
+	c.mx.Unlock()
+	switch balancer {
+	case BalancerRoundRobin:
+		return c.roundRobinConn()
+	case BalancerFastest:
+		return c.BestConn()
+	}
+	return c.ActiveConn()
+}
+
+func (c *Cluster) roundRobinConn() *Conn {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	l := len(c.active)
+	if l < 1 {
+		return nil
+	}
+	c.next = (c.next + 1) % l
+	return c.active[c.next]
+}
+
 // RankConn return list of connections with avg speed, include also not working connections (if some last pings failed)
 func (c *Cluster) RankConn() map[*Conn]int64 {
 	rt := make(map[*Conn]int64)
@@ -126,6 +195,49 @@ func (c *Cluster) BestConn() *Conn {
 	return maxK
 }
 
+// Use adds interceptors to all connections of cluster, they receive NodeInfo of connection in Call.Node.
+// Pings made by Check pass through them too
+func (c *Cluster) Use(interceptors ...Interceptor) {
+	c.mx.Lock()
+	first := !c.nodeInfoUsed
+	c.nodeInfoUsed = true
+	c.mx.Unlock()
+	for conn := range c.conn {
+		if first {
+			conn.Use(c.nodeInterceptor(conn))
+		}
+		conn.Use(interceptors...)
+	}
+}
+
+func (c *Cluster) nodeInterceptor(conn *Conn) Interceptor {
+	return func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			call.Node = c.nodeInfo(conn)
+			return next(call)
+		}
+	}
+}
+
+func (c *Cluster) nodeInfo(conn *Conn) *NodeInfo {
+	info := &NodeInfo{Cluster: c}
+	c.mx.Lock()
+	for _, active := range c.active {
+		if active == conn {
+			info.Active = true
+			break
+		}
+	}
+	c.mx.Unlock()
+
+	stats := c.conn[conn]
+	stats.mx.Lock()
+	info.AvgPing = time.Duration(stats.avg)
+	info.PingErrors = stats.errors
+	stats.mx.Unlock()
+	return info
+}
+
 // Check call Ping for all connections and save active
 func (c *Cluster) Check() {
 	var (
@@ -140,6 +252,9 @@ func (c *Cluster) Check() {
 		err = conn.Ping()
 		elapsed := time.Since(start)
 		val.NewCheck(elapsed.Nanoseconds(), err != nil)
+		if c.metrics != nil {
+			c.metrics.NodeChecked(conn.GetHost(), elapsed, time.Duration(val.Avg()), err)
+		}
 
 		if err == nil {
 			res = append(res, conn)
@@ -150,6 +265,10 @@ func (c *Cluster) Check() {
 		}
 	}
 
+	if c.metrics != nil {
+		c.metrics.ClusterChecked(len(res), len(c.conn))
+	}
+
 	if len(res) == 0 {
 		if c.onDown != nil {
 			c.onDown()
@@ -112,3 +112,19 @@ func TestBestTransportOthers(t *testing.T) {
 	assert.Equal(t, conn1.Host, active_host(cl.BestConn()))
 
 }
+
+func TestCluster_NextConn(t *testing.T) {
+	tr := getMockTransport("1")
+	cl := NewCluster(NewConn("host1", tr), NewConn("host2", tr))
+	assert.Nil(t, cl.NextConn())
+	cl.Check()
+
+	assert.Error(t, cl.SetBalancer("weighted"))
+	assert.NoError(t, cl.SetBalancer(BalancerRoundRobin))
+	first, second := cl.NextConn(), cl.NextConn()
+	assert.NotEqual(t, first.Host, second.Host)
+	assert.Equal(t, first, cl.NextConn())
+
+	assert.NoError(t, cl.SetBalancer(BalancerFastest))
+	assert.NotNil(t, cl.NextConn())
+}
@@ -1,9 +1,13 @@
 package clickhouse
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
@@ -15,6 +19,10 @@ type Conn struct {
 	Host      string
 	transport Transport
 	params    url.Values
+	location  *time.Location
+
+	interceptors []Interceptor
+	handler      Handler
 }
 
 // NewConn creates default connection to db
@@ -28,6 +36,7 @@ func NewConn(host string, t Transport) *Conn {
 		Host:      host,
 		transport: t,
 		params:    url.Values{},
+		location:  time.UTC,
 	}
 }
 
@@ -43,7 +52,7 @@ func NewAuthConn(host string, t Transport, user, pass string) *Conn {
 // TODO: calculate query time for cluster ranking
 func (c *Conn) Ping() (err error) {
 	var res string
-	res, err = c.Exec(Query{Stmt: "SELECT+1"}, true)
+	res, err = c.exec(CallPing, Query{Stmt: "SELECT+1"}, true)
 	if err == nil {
 		if !strings.Contains(res, successTestResponse) {
 			err = fmt.Errorf("Clickhouse host response was '%s', expected '%s'.", res, successTestResponse)
@@ -53,9 +62,39 @@ func (c *Conn) Ping() (err error) {
 	return err
 }
 
-// Exec pass query to self transport
+// Exec pass query to self transport through interceptors, server exception is returned as *DbError
 func (c *Conn) Exec(q Query, readOnly bool) (res string, err error) {
-	return c.transport.Exec(c.GetHost(), c.GetParams().Encode(), q, readOnly)
+	return c.exec(CallExec, q, readOnly)
+}
+
+func (c *Conn) exec(kind string, q Query, readOnly bool) (string, error) {
+	body, err := c.call(kind, q, readOnly)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	buf := new(strings.Builder)
+	_, err = io.Copy(buf, body)
+	return buf.String(), err
+}
+
+// Stream returns response body without reading it into memory, transports without StreamTransport support
+// read whole response first
+func (c *Conn) Stream(q Query, readOnly bool) (io.ReadCloser, error) {
+	return c.call(CallStream, q, readOnly)
+}
+
+// InsertFrom streams r as data of INSERT INTO table FORMAT format, e.g. CSV file or S3 object body.
+// Data is sent in chunks while it is read, so it is never kept in memory whole. Table may list columns: "clicks (id, url)"
+func (c *Conn) InsertFrom(ctx context.Context, table, format string, r io.Reader) error {
+	if r == nil {
+		return errors.New("Reader is nil")
+	}
+	q := NewQuery(fmt.Sprintf("INSERT INTO %s FORMAT %s", table, format))
+	q.SetContext(ctx)
+	// hide concrete reader type, so request is always chunked and caller's reader is not closed
+	q.body = io.NopCloser(r)
+	return q.Exec(c)
 }
 
 func (c *Conn) SetParams(params url.Values) {
@@ -73,6 +112,38 @@ func (c *Conn) GetParams() url.Values {
 	return c.params
 }
 
+// SetLocation sets time zone for reading Date and DateTime values, it should match server or column time zone.
+// Default is UTC
+func (c *Conn) SetLocation(loc *time.Location) {
+	c.location = loc
+}
+
+// Location return time zone used for reading Date and DateTime values
+func (c *Conn) Location() *time.Location {
+	if c.location == nil {
+		return time.UTC
+	}
+	return c.location
+}
+
+// UseServerLocation loads server time zone and uses it for reading Date and DateTime values
+func (c *Conn) UseServerLocation() error {
+	res, err := c.Exec(Query{Stmt: "SELECT+timezone()"}, true)
+	if err == nil {
+		err = errorFromResponse(res)
+	}
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(strings.TrimSpace(res))
+	if err != nil {
+		return err
+	}
+	c.SetLocation(loc)
+	return nil
+}
+
 // GetHost return connection hostname, usefull for clusters
 func (c *Conn) GetHost() string {
 	return c.Host
@@ -1,8 +1,14 @@
 package clickhouse
 
 import (
+	"context"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -54,3 +60,49 @@ func TestAuthConn(t *testing.T) {
 	assert.NoError(t, conn.Ping())
 
 }
+
+func TestConn_UseServerLocation(t *testing.T) {
+	conn := NewConn("host.local", getMockTransport("Europe/Moscow\n"))
+	assert.NoError(t, conn.UseServerLocation())
+	assert.Equal(t, "Europe/Moscow", conn.Location().String())
+
+	conn = NewConn("host.local", getMockTransport("Code: 62, "))
+	assert.Error(t, conn.UseServerLocation())
+	assert.Equal(t, time.UTC, conn.Location())
+}
+
+func TestConn_InsertFrom(t *testing.T) {
+	var (
+		query, body string
+		chunked     bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if strings.Contains(query, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist")
+		}
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	err := conn.InsertFrom(context.Background(), "clicks (id, url)", "CSV", strings.NewReader("1,a\n2,b\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO clicks (id, url) FORMAT CSV", query)
+	assert.Equal(t, "1,a\n2,b\n", body)
+	assert.True(t, chunked)
+
+	err = conn.InsertFrom(context.Background(), "missing", "CSV", strings.NewReader("1\n"))
+	assert.Error(t, err)
+	assert.Equal(t, 60, err.(*DbError).Code())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = conn.InsertFrom(ctx, "clicks", "CSV", strings.NewReader("1\n"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.Error(t, conn.InsertFrom(context.Background(), "clicks", "CSV", nil))
+}
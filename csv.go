@@ -0,0 +1,189 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV          = "CSV"
+	FormatCSVWithNames = "CSVWithNames"
+)
+
+// CSVOptions control CSV export and import
+type CSVOptions struct {
+	// Delimiter is field separator, default is comma
+	Delimiter rune
+	// NoHeader is set for data without column names in first line
+	NoHeader bool
+	// AllowErrorsNum and AllowErrorsRatio let server skip this amount of bad rows on import
+	AllowErrorsNum   int
+	AllowErrorsRatio float64
+}
+
+func (o CSVOptions) format() string {
+	if o.NoHeader {
+		return FormatCSV
+	}
+	return FormatCSVWithNames
+}
+
+func (o CSVOptions) apply(q *Query) {
+	if o.Delimiter != 0 && o.Delimiter != ',' {
+		q.params.Set("format_csv_delimiter", string(o.Delimiter))
+	}
+	if o.AllowErrorsNum > 0 {
+		q.params.Set("input_format_allow_errors_num", strconv.Itoa(o.AllowErrorsNum))
+	}
+	if o.AllowErrorsRatio > 0 {
+		q.params.Set("input_format_allow_errors_ratio", strconv.FormatFloat(o.AllowErrorsRatio, 'g', -1, 64))
+	}
+}
+
+// ExportCSV streams query result in CSV format into w, e.g. file or http.ResponseWriter.
+// It returns amount of written bytes. When query fails after some rows were sent, server writes exception
+// at the end of data, it is already copied into w and ExportCSV returns it as *DbError
+func ExportCSV(ctx context.Context, conn Connector, q Query, w io.Writer, opts CSVOptions) (int64, error) {
+	if conn == nil {
+		return 0, errors.New("Connection pointer is nil")
+	}
+	q.Stmt += " FORMAT " + opts.format()
+	q.SetContext(ctx)
+	q.params = cloneParams(q.params)
+	opts.apply(&q)
+
+	body, err := streamQuery(conn, q)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return copyResponse(w, body)
+}
+
+// ImportCSV streams CSV data into table. Column names from header are checked against insertable columns
+// of table in system.columns before data is sent, data without header is inserted into all columns
+func ImportCSV(ctx context.Context, conn Connector, table string, r io.Reader, opts CSVOptions) error {
+	if conn == nil {
+		return errors.New("Connection pointer is nil")
+	}
+	if r == nil {
+		return errors.New("Reader is nil")
+	}
+
+	stmt := "INSERT INTO " + table
+	if !opts.NoHeader {
+		br := bufio.NewReader(r)
+		header, err := readCSVHeader(br, opts.Delimiter)
+		if err != nil {
+			return err
+		}
+		if err = checkCSVColumns(ctx, conn, table, header); err != nil {
+			return err
+		}
+		names := make([]string, len(header))
+		for i, name := range header {
+			names[i] = quoteIdentifier(name)
+		}
+		stmt += " (" + strings.Join(names, ",") + ")"
+		r = br
+		// header line is consumed, so rest of data is plain CSV
+		opts.NoHeader = true
+	}
+
+	q := NewQuery(stmt + " FORMAT " + opts.format())
+	q.SetContext(ctx)
+	opts.apply(&q)
+	q.body = io.NopCloser(r)
+	return q.Exec(conn)
+}
+
+// readCSVHeader reads column names from first line
+func readCSVHeader(r *bufio.Reader, delimiter rune) (Columns, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return nil, errors.New("CSV data has no header")
+		}
+		return nil, err
+	}
+	cr := csv.NewReader(strings.NewReader(line))
+	if delimiter != 0 {
+		cr.Comma = delimiter
+	}
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read CSV header: %v", err)
+	}
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+	}
+	return header, nil
+}
+
+// checkCSVColumns returns error listing header columns which table has not or which cannot be inserted
+func checkCSVColumns(ctx context.Context, conn Connector, table string, header Columns) error {
+	database, name := "", table
+	if pos := strings.LastIndexByte(table, '.'); pos >= 0 {
+		database, name = table[:pos], table[pos+1:]
+	}
+	database, name = strings.Trim(database, "`\""), strings.Trim(name, "`\"")
+
+	var q Query
+	if database == "" {
+		q = NewQuery("SELECT name, default_kind FROM system.columns WHERE database = currentDatabase() AND table = :value:", name)
+	} else {
+		q = NewQuery("SELECT name, default_kind FROM system.columns WHERE database = :value: AND table = :value:", database, name)
+	}
+	q.SetContext(ctx)
+
+	columns := make(map[string]string)
+	iter := q.Iter(conn)
+	var col, kind string
+	for iter.Scan(&col, &kind) {
+		columns[col] = kind
+	}
+	if iter.Error() != nil {
+		return iter.Error()
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("Table %s does not exist", table)
+	}
+
+	var unknown, calculated []string
+	for _, col := range header {
+		kind, ok := columns[col]
+		switch {
+		case !ok:
+			unknown = append(unknown, col)
+		case kind == "MATERIALIZED" || kind == "ALIAS":
+			calculated = append(calculated, col)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("Table %s has no columns %s", table, strings.Join(unknown, ", "))
+	}
+	if len(calculated) > 0 {
+		return fmt.Errorf("Columns %s of table %s cannot be inserted", strings.Join(calculated, ", "), table)
+	}
+	return nil
+}
+
+// quoteIdentifier quotes column name with backticks
+func quoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(name) + "`"
+}
+
+func cloneParams(params url.Values) url.Values {
+	res := make(url.Values, len(params))
+	for k, v := range params {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// csvServer answers system.columns queries and records inserts
+type csvServer struct {
+	columns string
+	query   string
+	params  string
+	body    string
+}
+
+func (s *csvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.params = r.URL.RawQuery
+	data, _ := io.ReadAll(r.Body)
+	if q := r.URL.Query().Get("query"); q != "" {
+		s.query = q
+		s.body = string(data)
+		return
+	}
+	s.query = string(data)
+	if strings.Contains(s.query, "system.columns") {
+		fmt.Fprint(w, s.columns)
+		return
+	}
+	fmt.Fprint(w, "id,name\n1,a\n")
+}
+
+func TestExportCSV(t *testing.T) {
+	server := &csvServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	conn := NewConn(ts.URL, NewHttpTransport())
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	n, err := ExportCSV(ctx, conn, NewQuery("SELECT id, name FROM t"), buf, CSVOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), n)
+	assert.Equal(t, "id,name\n1,a\n", buf.String())
+	assert.Equal(t, "SELECT id, name FROM t FORMAT CSVWithNames", server.query)
+
+	q := NewQuery("SELECT id FROM t")
+	_, err = ExportCSV(ctx, conn, q, io.Discard, CSVOptions{Delimiter: ';', NoHeader: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM t FORMAT CSV", server.query)
+	assert.Contains(t, server.params, "format_csv_delimiter=%3B")
+	assert.Empty(t, q.params)
+
+	_, err = ExportCSV(ctx, NewConn(getHost(), getMockTransport("Code: 60, e.displayText() = DB::Exception: Table default.t doesn't exist")), q, io.Discard, CSVOptions{})
+	assert.Error(t, err)
+	_, err = ExportCSV(ctx, nil, q, io.Discard, CSVOptions{})
+	assert.Error(t, err)
+
+	// exception after first rows
+	resp := "id,name\n1,a\nCode: 241. DB::Exception: Memory limit (for query) exceeded\n"
+	buf.Reset()
+	_, err = ExportCSV(ctx, NewConn(getHost(), getMockTransport(resp)), q, buf, CSVOptions{})
+	if assert.IsType(t, &DbError{}, err) {
+		assert.Equal(t, 241, err.(*DbError).Code())
+	}
+	assert.Equal(t, resp, buf.String())
+
+	// same text inside of data is not exception
+	_, err = ExportCSV(ctx, NewConn(getHost(), getMockTransport("msg\n\"Code: 1, ok\"\n")), q, io.Discard, CSVOptions{})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = ExportCSV(ctx, conn, q, io.Discard, CSVOptions{})
+	assert.Error(t, err)
+}
+
+func TestImportCSV(t *testing.T) {
+	server := &csvServer{columns: "id\t\nname\tDEFAULT\ntotal\tMATERIALIZED\n"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	conn := NewConn(ts.URL, NewHttpTransport())
+	ctx := context.Background()
+
+	err := ImportCSV(ctx, conn, "db.t", strings.NewReader("id;\"name\"\r\n1;a\n2;b\n"), CSVOptions{Delimiter: ';', AllowErrorsNum: 10, AllowErrorsRatio: 0.1})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO db.t (`id`,`name`) FORMAT CSV", server.query)
+	assert.Equal(t, "1;a\n2;b\n", server.body)
+	assert.Contains(t, server.params, "input_format_allow_errors_num=10")
+	assert.Contains(t, server.params, "input_format_allow_errors_ratio=0.1")
+	assert.Contains(t, server.params, "format_csv_delimiter=%3B")
+
+	err = ImportCSV(ctx, conn, "t", strings.NewReader("1,a\n"), CSVOptions{NoHeader: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t FORMAT CSV", server.query)
+	assert.Equal(t, "1,a\n", server.body)
+
+	server.query = ""
+	err = ImportCSV(ctx, conn, "t", strings.NewReader("id,title\n1,a\n"), CSVOptions{})
+	assert.EqualError(t, err, "Table t has no columns title")
+	assert.Contains(t, server.query, "database = currentDatabase() AND table = 't'")
+
+	err = ImportCSV(ctx, conn, "t", strings.NewReader("id,total\n1,2\n"), CSVOptions{})
+	assert.EqualError(t, err, "Columns total of table t cannot be inserted")
+
+	err = ImportCSV(ctx, conn, "t", strings.NewReader(""), CSVOptions{})
+	assert.Error(t, err)
+
+	server.columns = ""
+	err = ImportCSV(ctx, conn, "t", strings.NewReader("id\n1\n"), CSVOptions{})
+	assert.EqualError(t, err, "Table t does not exist")
+
+	assert.Error(t, ImportCSV(ctx, nil, "t", strings.NewReader(""), CSVOptions{}))
+	assert.Error(t, ImportCSV(ctx, conn, "t", nil, CSVOptions{}))
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "`id`", quoteIdentifier("id"))
+	assert.Equal(t, "`first name`", quoteIdentifier("first name"))
+	assert.Equal(t, "`a\\`b\\\\`", quoteIdentifier("a`b\\"))
+}
@@ -0,0 +1,91 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Date sends time as ClickHouse Date, only calendar day in time's own location is kept: clickhouse.Date(t)
+	Date time.Time
+	// Date32 is Date with extended range
+	Date32 time.Time
+	// DateTime sends time as ClickHouse DateTime with seconds precision: clickhouse.DateTime(t)
+	DateTime time.Time
+	// DateTime64 sends time with sub-second precision, Precision is amount of fractional digits (0-9)
+	DateTime64 struct {
+		Time      time.Time
+		Precision int
+	}
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// formatDate makes Date literal, it is accepted by Date and Date32 columns
+func formatDate(t time.Time) string {
+	return "'" + t.Format(dateLayout) + "'"
+}
+
+// formatDateTime makes DateTime literal with explicit time zone, so value does not depend on server or column time zone
+func formatDateTime(t time.Time) string {
+	return fmt.Sprintf("toDateTime('%s', 'UTC')", t.UTC().Format(dateTimeLayout))
+}
+
+func (v DateTime64) checkPrecision() error {
+	if v.Precision < 0 || v.Precision > 9 {
+		return fmt.Errorf("DateTime64 precision %d is out of range 0-9", v.Precision)
+	}
+	return nil
+}
+
+func formatDateTime64(v DateTime64) (string, error) {
+	if err := v.checkPrecision(); err != nil {
+		return "", err
+	}
+	layout := dateTimeLayout
+	if v.Precision > 0 {
+		layout += "." + strings.Repeat("0", v.Precision)
+	}
+	return fmt.Sprintf("toDateTime64('%s', %d, 'UTC')", v.Time.UTC().Format(layout), v.Precision), nil
+}
+
+// parseTime reads Date, DateTime and DateTime64 text representation in loc time zone
+func parseTime(data string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch {
+	case data == "0000-00-00" || data == "0000-00-00 00:00:00":
+		return time.Time{}, nil
+	case len(data) == len(dateLayout):
+		return time.ParseInLocation(dateLayout, data, loc)
+	case len(data) >= len(dateTimeLayout):
+		// fractional part of DateTime64 is accepted by parser even without layout for it
+		return time.ParseInLocation(dateTimeLayout, data, loc)
+	}
+	return time.Time{}, fmt.Errorf("Cannot parse %q as Date or DateTime", data)
+}
+
+// parseDateTime reads DateTime, it may be formatted as unix timestamp (date_time_output_format=unix_timestamp)
+func parseDateTime(data string, loc *time.Location) (time.Time, error) {
+	if sec, err := strconv.ParseInt(data, 10, 64); err == nil {
+		if loc == nil {
+			loc = time.UTC
+		}
+		return time.Unix(sec, 0).In(loc), nil
+	}
+	return parseTime(data, loc)
+}
+
+func fractionDigits(data string) int {
+	pos := strings.IndexByte(data, '.')
+	if pos < 0 {
+		return 0
+	}
+	return len(data) - pos - 1
+}
@@ -0,0 +1,97 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalTime(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*3600)
+	tm := time.Date(2017, 4, 10, 1, 2, 3, 123456789, msk)
+
+	assert.Equal(t, "toDateTime('2017-04-09 22:02:03', 'UTC')", mustMarshal(t, tm))
+	assert.Equal(t, "toDateTime('2017-04-09 22:02:03', 'UTC')", mustMarshal(t, DateTime(tm)))
+	assert.Equal(t, "'2017-04-10'", mustMarshal(t, Date(tm)))
+	assert.Equal(t, "'2017-04-10'", mustMarshal(t, Date32(tm)))
+	assert.Equal(t, "toDateTime64('2017-04-09 22:02:03.123', 3, 'UTC')", mustMarshal(t, DateTime64{tm, 3}))
+	assert.Equal(t, "toDateTime64('2017-04-09 22:02:03', 0, 'UTC')", mustMarshal(t, DateTime64{Time: tm}))
+	assert.Equal(t, "toDateTime64('2017-04-09 22:02:03.123456789', 9, 'UTC')", mustMarshal(t, DateTime64{tm, 9}))
+	assert.Equal(t, "['2017-04-10']", mustMarshal(t, []Date{Date(tm)}))
+
+	_, err := marshal(DateTime64{tm, 10})
+	assert.Error(t, err)
+	_, err = appendJSON(nil, DateTime64{tm, -1})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalTime(t *testing.T) {
+	var (
+		tm  time.Time
+		d   Date
+		d32 Date32
+		dt  DateTime
+		dt6 DateTime64
+		arr []time.Time
+	)
+
+	assert.NoError(t, unmarshal(&tm, "2017-04-10"))
+	assert.Equal(t, time.Date(2017, 4, 10, 0, 0, 0, 0, time.UTC), tm)
+
+	assert.NoError(t, unmarshal(&d, "2017-04-10"))
+	assert.Equal(t, time.Date(2017, 4, 10, 0, 0, 0, 0, time.UTC), time.Time(d))
+
+	assert.NoError(t, unmarshal(&d32, "1900-01-01"))
+	assert.Equal(t, time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), time.Time(d32))
+
+	assert.NoError(t, unmarshal(&dt, "2017-04-10 01:02:03"))
+	assert.Equal(t, time.Date(2017, 4, 10, 1, 2, 3, 0, time.UTC), time.Time(dt))
+
+	assert.NoError(t, unmarshal(&dt6, "2017-04-10 01:02:03.120"))
+	assert.Equal(t, DateTime64{time.Date(2017, 4, 10, 1, 2, 3, 120000000, time.UTC), 3}, dt6)
+
+	assert.NoError(t, unmarshal(&tm, "0000-00-00 00:00:00"))
+	assert.True(t, tm.IsZero())
+
+	// unix timestamps are read only into DateTime
+	assert.NoError(t, unmarshal(&dt, "1491786123"))
+	assert.Equal(t, int64(1491786123), time.Time(dt).Unix())
+	assert.Error(t, unmarshal(&tm, "1491786123"))
+	assert.Error(t, unmarshal(&d, "20170410"))
+
+	assert.NoError(t, unmarshal(&arr, "['2017-04-10','2017-04-10 01:02:03.5']"))
+	assert.Equal(t, []time.Time{
+		time.Date(2017, 4, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 4, 10, 1, 2, 3, 500000000, time.UTC),
+	}, arr)
+
+	assert.Error(t, unmarshal(&tm, "10/04/2017"))
+	assert.Error(t, unmarshal(&d, ""))
+}
+
+func TestUnmarshalTimeLocation(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*3600)
+	var tm time.Time
+
+	assert.NoError(t, decoder{loc: msk}.unmarshal(&tm, "2017-04-10 01:02:03"))
+	assert.Equal(t, time.Date(2017, 4, 9, 22, 2, 3, 0, time.UTC), tm.UTC())
+}
+
+func TestIter_ScanLocation(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*3600)
+	conn := NewConn(getHost(), getMockTransport("2017-04-10 01:02:03"))
+	assert.Equal(t, time.UTC, conn.Location())
+	conn.SetLocation(msk)
+
+	var tm time.Time
+	iter := NewQuery("SELECT now()").Iter(conn)
+	assert.True(t, iter.Scan(&tm))
+	assert.Equal(t, time.Date(2017, 4, 10, 1, 2, 3, 0, msk), tm)
+
+	q := NewQuery("SELECT now()")
+	q.SetLocation(time.UTC)
+	iter = q.Iter(conn)
+	assert.True(t, iter.Scan(&tm))
+	assert.Equal(t, time.Date(2017, 4, 10, 1, 2, 3, 0, time.UTC), tm)
+}
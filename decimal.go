@@ -0,0 +1,115 @@
+package clickhouse
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimal is exact fixed point number for Decimal32/64/128/256 columns.
+// Value is unscaled integer, so Decimal{big.NewInt(12345), 2} means 123.45
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+// NewDecimal creates decimal from unscaled value and scale
+func NewDecimal(unscaled int64, scale int) Decimal {
+	return Decimal{Value: big.NewInt(unscaled), Scale: scale}
+}
+
+// ParseDecimal parses decimal text like -123.4500, scale is taken from amount of fractional digits
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	scale := 0
+	if pos := strings.IndexByte(s, '.'); pos >= 0 {
+		scale = len(s) - pos - 1
+		digits = s[:pos] + s[pos+1:]
+	}
+
+	v := new(big.Int)
+	if _, ok := v.SetString(digits, 10); !ok {
+		return Decimal{}, fmt.Errorf("Cannot parse %q as Decimal", s)
+	}
+	return Decimal{Value: v, Scale: scale}, nil
+}
+
+func (d Decimal) unscaled() *big.Int {
+	if d.Value == nil {
+		return new(big.Int)
+	}
+	return d.Value
+}
+
+// checkScale rejects negative scale, ClickHouse decimals have only fractional digits
+func (d Decimal) checkScale() error {
+	if d.Scale < 0 {
+		return fmt.Errorf("Decimal scale %d is negative", d.Scale)
+	}
+	return nil
+}
+
+// String formats decimal with exactly Scale fractional digits, negative Scale adds trailing zeros
+func (d Decimal) String() string {
+	v := d.unscaled()
+	s := new(big.Int).Abs(v).String()
+	if d.Scale < 0 && v.Sign() != 0 {
+		s += strings.Repeat("0", -d.Scale)
+	}
+	if d.Scale > 0 {
+		if len(s) <= d.Scale {
+			s = strings.Repeat("0", d.Scale-len(s)+1) + s
+		}
+		s = s[:len(s)-d.Scale] + "." + s[len(s)-d.Scale:]
+	}
+	if v.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// Rescale changes scale of decimal, it fails instead of dropping fractional digits
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	if err := d.checkScale(); err != nil {
+		return Decimal{}, err
+	}
+	if scale < 0 {
+		return Decimal{}, fmt.Errorf("Decimal scale %d is negative", scale)
+	}
+	v := d.unscaled()
+	if scale >= d.Scale {
+		m := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.Scale)), nil)
+		return Decimal{Value: m.Mul(m, v), Scale: scale}, nil
+	}
+
+	m := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale-scale)), nil)
+	q, r := new(big.Int).QuoRem(v, m, new(big.Int))
+	if r.Sign() != 0 {
+		return Decimal{}, fmt.Errorf("Decimal %s cannot be represented with scale %d", d, scale)
+	}
+	return Decimal{Value: q, Scale: scale}, nil
+}
+
+// MarshalJSON writes decimal as JSON number without loss of precision
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if err := d.checkScale(); err != nil {
+		return nil, err
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts both numbers and quoted strings, ClickHouse uses both depending on settings
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	res, err := ParseDecimal(string(data))
+	if err != nil {
+		return err
+	}
+	*d = res
+	return nil
+}
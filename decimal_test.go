@@ -0,0 +1,81 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	d, err := ParseDecimal("123.4500")
+	assert.NoError(t, err)
+	assert.Equal(t, Decimal{Value: big.NewInt(1234500), Scale: 4}, d)
+	assert.Equal(t, "123.4500", d.String())
+
+	d, err = ParseDecimal("-0.05")
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.05", d.String())
+
+	d, err = ParseDecimal("170141183460469231731687303715884105727")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, d.Scale)
+	assert.Equal(t, "170141183460469231731687303715884105727", d.String())
+
+	for _, s := range []string{"", "1.2.3", "abc", "1e5", "0x10"} {
+		_, err = ParseDecimal(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestDecimal_String(t *testing.T) {
+	assert.Equal(t, "0", Decimal{}.String())
+	assert.Equal(t, "0.000", Decimal{Scale: 3}.String())
+	assert.Equal(t, "0.005", NewDecimal(5, 3).String())
+	assert.Equal(t, "-1.50", NewDecimal(-150, 2).String())
+	assert.Equal(t, "42", NewDecimal(42, 0).String())
+	assert.Equal(t, "4200", NewDecimal(42, -2).String())
+}
+
+func TestDecimal_Rescale(t *testing.T) {
+	d, err := NewDecimal(12345, 2).Rescale(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "123.4500", d.String())
+
+	d, err = d.Rescale(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "123.45", d.String())
+
+	_, err = d.Rescale(1)
+	assert.Error(t, err)
+
+	_, err = d.Rescale(-1)
+	assert.Error(t, err)
+	_, err = NewDecimal(42, -2).Rescale(2)
+	assert.Error(t, err)
+	_, err = marshal(NewDecimal(42, -2))
+	assert.Error(t, err)
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	var obj struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":1.10,"b":"-2.000"}`), &obj))
+	assert.Equal(t, "1.10", obj.A.String())
+	assert.Equal(t, "-2.000", obj.B.String())
+
+	res, err := json.Marshal(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1.10,"b":-2.000}`, string(res))
+
+	// only literal null is null
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":null}`), &obj))
+	assert.Equal(t, "1.10", obj.A.String())
+	assert.Error(t, json.Unmarshal([]byte(`{"a":"null"}`), &obj))
+
+	_, err = json.Marshal(NewDecimal(1, -1))
+	assert.Error(t, err)
+}
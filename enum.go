@@ -0,0 +1,134 @@
+package clickhouse
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Enum is definition of Enum8/Enum16 column, it converts names to values and back.
+// Text formats return enum names, so Enum is needed to scan them into integers:
+//
+//	status, _ := clickhouse.ParseEnum("Enum8('active' = 1, 'deleted' = 2)")
+//	var code int8
+//	iter.Scan(status.Dest(&code))
+type Enum struct {
+	values map[string]int16
+	names  map[int16]string
+}
+
+// NewEnum creates enum from name to value mapping
+func NewEnum(values map[string]int16) *Enum {
+	e := &Enum{
+		values: make(map[string]int16, len(values)),
+		names:  make(map[int16]string, len(values)),
+	}
+	for name, value := range values {
+		e.values[name] = value
+		e.names[value] = name
+	}
+	return e
+}
+
+// ParseEnum creates enum from column type like Enum8('a' = 1, 'b' = 2)
+func ParseEnum(typ string) (*Enum, error) {
+	typ = strings.TrimSpace(typ)
+	pos := strings.IndexByte(typ, '(')
+	if pos < 0 || !strings.HasSuffix(typ, ")") || (typ[:pos] != "Enum8" && typ[:pos] != "Enum16" && typ[:pos] != "Enum") {
+		return nil, fmt.Errorf("Type %q is not Enum", typ)
+	}
+
+	values := make(map[string]int16)
+	p := literalParser{s: typ[pos+1 : len(typ)-1]}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != '\'' {
+			return nil, p.errorf("expected enum name")
+		}
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != '=' {
+			return nil, p.errorf("expected '='")
+		}
+		p.pos++
+		p.skipSpaces()
+		value, err := p.parseScalar()
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseInt(value.text, 10, 16)
+		if err != nil {
+			return nil, p.errorf("bad enum value: %v", err)
+		}
+		values[name.text] = int16(v)
+
+		p.skipSpaces()
+		if p.pos == len(p.s) {
+			break
+		}
+		if p.s[p.pos] != ',' {
+			return nil, p.errorf("expected ','")
+		}
+		p.pos++
+	}
+	return NewEnum(values), nil
+}
+
+// Value returns numeric value of enum name
+func (e *Enum) Value(name string) (int16, bool) {
+	v, ok := e.values[name]
+	return v, ok
+}
+
+// Name returns enum name of numeric value
+func (e *Enum) Name(value int16) (string, bool) {
+	name, ok := e.names[value]
+	return name, ok
+}
+
+// Dest returns scan destination that stores numeric value of enum into ptr, ptr should point to any integer type
+func (e *Enum) Dest(ptr interface{}) interface{} {
+	return &enumDest{enum: e, ptr: ptr}
+}
+
+type enumDest struct {
+	enum *Enum
+	ptr  interface{}
+}
+
+func (d *enumDest) UnmarshalClickHouse(name string) error {
+	value, ok := d.enum.Value(name)
+	if !ok {
+		// server may return numbers for enums, e.g. after CAST
+		v, err := strconv.ParseInt(name, 10, 16)
+		if err != nil {
+			return fmt.Errorf("Unknown enum value %q", name)
+		}
+		value = int16(v)
+	}
+
+	v := reflect.ValueOf(d.ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("Type %T is not supported for enum values", d.ptr)
+	}
+	v = v.Elem()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(int64(value)) {
+			return fmt.Errorf("Enum value %d overflows %s", value, v.Type())
+		}
+		v.SetInt(int64(value))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value < 0 || v.OverflowUint(uint64(value)) {
+			return fmt.Errorf("Enum value %d overflows %s", value, v.Type())
+		}
+		v.SetUint(uint64(value))
+	default:
+		return fmt.Errorf("Type %T is not supported for enum values", d.ptr)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnum(t *testing.T) {
+	e, err := ParseEnum("Enum8('active' = 1, 'dele\\'ted' = -2,'a=b'=3)")
+	assert.NoError(t, err)
+
+	v, ok := e.Value("dele'ted")
+	assert.True(t, ok)
+	assert.Equal(t, int16(-2), v)
+
+	v, ok = e.Value("a=b")
+	assert.True(t, ok)
+	assert.Equal(t, int16(3), v)
+
+	name, ok := e.Name(1)
+	assert.True(t, ok)
+	assert.Equal(t, "active", name)
+
+	_, ok = e.Name(5)
+	assert.False(t, ok)
+
+	for _, s := range []string{"String", "Enum8()", "Enum8('a')", "Enum8('a' = x)", "Enum16('a' = 1 'b' = 2)", "Enum8('a' = 100000)"} {
+		_, err = ParseEnum(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestIter_ScanEnum(t *testing.T) {
+	status := NewEnum(map[string]int16{"active": 1, "deleted": 2, "big": 300})
+	conn := NewConn(getHost(), getMockTransport("active\tactive\n2\tdeleted\nbig\tbig\nunknown\tunknown"))
+	iter := NewQuery("SELECT status, status FROM t").Iter(conn)
+
+	var (
+		code int8
+		name string
+	)
+	assert.True(t, iter.Scan(status.Dest(&code), &name))
+	assert.Equal(t, int8(1), code)
+	assert.Equal(t, "active", name)
+
+	var ucode uint16
+	assert.True(t, iter.Scan(status.Dest(&ucode), &name))
+	assert.Equal(t, uint16(2), ucode)
+	assert.Equal(t, "deleted", name)
+
+	assert.False(t, iter.Scan(status.Dest(&code), &name))
+	assert.Error(t, iter.Error())
+
+	iter.err = nil
+	assert.False(t, iter.Scan(status.Dest(&code), &name))
+	assert.Error(t, iter.Error())
+}
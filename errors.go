@@ -1,7 +1,9 @@
 package clickhouse
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -38,9 +40,17 @@ func errorFromResponse(resp string) error {
 	}
 
 	if strings.Index(resp, "Code:") == 0 {
-		codeStr := resp[6:strings.Index(resp, ",")]
-		code, _ := strconv.Atoi(codeStr)
+		rest := strings.TrimLeft(resp[5:], " ")
+		end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if end < 0 {
+			end = len(rest)
+		}
+		code, _ := strconv.Atoi(rest[:end])
 		var msg string
+		// newer servers send "Code: 241. DB::Exception: ..."
+		if strings.HasPrefix(rest[end:], ". ") {
+			msg = strings.TrimSpace(rest[end+2:])
+		}
 		msgIndex := strings.Index(resp, "e.displayText() = ")
 		if msgIndex >= 0 {
 			msgIndex += 18
@@ -56,3 +66,51 @@ func errorFromResponse(resp string) error {
 
 	return nil
 }
+
+// exceptionFromTail returns server exception written at the end of streamed response
+func exceptionFromTail(tail []byte) error {
+	pos := bytes.LastIndex(tail, []byte("Code:"))
+	if pos < 0 {
+		return nil
+	}
+	return errorFromResponse(strings.TrimSpace(string(tail[pos:])))
+}
+
+// ExceptionReader keeps end of streamed response, server writes exception there when query fails
+// after part of result was sent with status 200:
+//
+//	r := clickhouse.NewExceptionReader(body)
+//	_, err := io.Copy(w, r)
+//	if err == nil {
+//		err = r.Exception()
+//	}
+type ExceptionReader struct {
+	tail tailReader
+}
+
+func NewExceptionReader(r io.Reader) *ExceptionReader {
+	return &ExceptionReader{tail: tailReader{r: r}}
+}
+
+func (r *ExceptionReader) Read(p []byte) (int, error) {
+	return r.tail.Read(p)
+}
+
+// Exception returns *DbError sent at the end of data which was read, nil if there is no exception.
+// Data may contain "Code:" too, so only complete exception message is taken
+func (r *ExceptionReader) Exception() error {
+	if dbErr, ok := exceptionFromTail(r.tail.tail).(*DbError); ok && strings.Contains(dbErr.Message(), "DB::Exception") {
+		return dbErr
+	}
+	return nil
+}
+
+// copyResponse copies streamed response into w, exception written by server after data is returned as *DbError
+func copyResponse(w io.Writer, body io.Reader) (int64, error) {
+	r := NewExceptionReader(body)
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return n, err
+	}
+	return n, r.Exception()
+}
@@ -1,6 +1,8 @@
 package clickhouse
 
 import (
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -37,3 +39,26 @@ func TestErrorFromResponse(t *testing.T) {
 	assert.Equal(t, resp, err.Response())
 	assert.Equal(t, "DB::Exception: Syntax error: failed at end of query.\nExpected identifier,", err.Message())
 }
+
+func TestErrorFromResponse_Formats(t *testing.T) {
+	err := errorFromResponse("Code: 241. DB::Exception: Memory limit (for query) exceeded. (MEMORY_LIMIT_EXCEEDED) (version 23.8.1.1)\n").(*DbError)
+	assert.Equal(t, 241, err.Code())
+	assert.Equal(t, "DB::Exception: Memory limit (for query) exceeded. (MEMORY_LIMIT_EXCEEDED) (version 23.8.1.1)", err.Message())
+
+	err = errorFromResponse("Code: 159").(*DbError)
+	assert.Equal(t, 159, err.Code())
+	assert.Equal(t, "", err.Message())
+}
+
+func TestExceptionReader(t *testing.T) {
+	r := NewExceptionReader(strings.NewReader("1\n2\nCode: 241. DB::Exception: Memory limit (for query) exceeded\n"))
+	_, err := io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	if assert.Error(t, r.Exception()) {
+		assert.Equal(t, 241, r.Exception().(*DbError).Code())
+	}
+
+	r = NewExceptionReader(strings.NewReader("Code: 1\n"))
+	io.Copy(io.Discard, r)
+	assert.NoError(t, r.Exception())
+}
@@ -1,8 +1,11 @@
 package clickhouse
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
 )
@@ -15,8 +18,10 @@ type (
 	// Row value
 	Row []interface{}
 	// Rows array or values
-	Rows              []Row
-	Array             []interface{}
+	Rows  []Row
+	Array []interface{}
+	// Tuple value, it is sent as tuple(...) and read from (...) literals
+	Tuple             []interface{}
 	VisitParamsString map[string]interface{}
 	StringArray       []string
 )
@@ -49,14 +54,21 @@ func BuildMultiInsert(tbl string, cols Columns, rows Rows) (Query, error) {
 	colCount := len(cols)
 	rowCount := len(rows)
 	args = make([]interface{}, colCount*rowCount)
+	literals := make([]string, colCount*rowCount)
 	argi := 0
 
-	for _, row := range rows {
+	for r, row := range rows {
 		if len(row) != colCount {
 			return Query{}, errors.New("Amount of row items does not match column count")
 		}
-		for _, val := range row {
+		for i, val := range row {
+			// literals are rendered once here and reused by transport
+			lit, err := marshal(val)
+			if err != nil {
+				return Query{}, fmt.Errorf("row %d column %s: %v", r, cols[i], err)
+			}
 			args[argi] = val
+			literals[argi] = lit
 			argi++
 		}
 	}
@@ -68,5 +80,98 @@ func BuildMultiInsert(tbl string, cols Columns, rows Rows) (Query, error) {
 
 	stmt = fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", tbl, strings.Join(cols, ","), batch)
 
-	return NewQuery(stmt, args...), nil
+	q := NewQuery(stmt, args...)
+	q.literals = literals
+	return q, nil
+}
+
+// ErrInsertAborted is returned by insert writers after Abort(nil)
+var ErrInsertAborted = errors.New("Insert is aborted")
+
+// InsertWriter streams data of insert request. Close finishes data and waits for server response,
+// Abort breaks request, so server does not commit rows which were already sent
+type InsertWriter interface {
+	io.WriteCloser
+	Abort(err error)
+}
+
+// NewInsertWriter starts INSERT ... FORMAT request, data written into result is streamed as request body:
+//
+//	w, err := clickhouse.NewInsertWriter(conn, "clicks", "CSVWithNames", nil)
+//	if _, err = io.Copy(w, file); err != nil {
+//		w.Abort(err)
+//		return err
+//	}
+//	err = w.Close() // waits for server response
+func NewInsertWriter(conn Connector, table, format string, cols Columns) (InsertWriter, error) {
+	if conn == nil {
+		return nil, errors.New("Connection pointer is nil")
+	}
+	stmt := "INSERT INTO " + table
+	if len(cols) > 0 {
+		stmt += " (" + strings.Join(cols, ",") + ")"
+	}
+	s := &insertStream{}
+	s.start(conn, NewQuery(stmt+" FORMAT "+format))
+	return s, nil
+}
+
+// insertStream sends data written into it as body of insert request
+type insertStream struct {
+	buf    *bufio.Writer
+	pipe   *io.PipeWriter
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// start runs insert query q in background, its body is read from stream
+func (s *insertStream) start(conn Connector, q Query) {
+	pr, pw := io.Pipe()
+	s.buf = bufio.NewWriterSize(pw, 64*1024)
+	s.pipe = pw
+	s.done = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(q.Context())
+	s.cancel = cancel
+	q.SetContext(ctx)
+	q.body = pr
+	go func() {
+		defer cancel()
+		err := q.Exec(conn)
+		// unblock writer if request finished before reading whole body
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			pr.CloseWithError(errors.New("Insert request is finished"))
+		}
+		s.err = err
+		close(s.done)
+	}()
+}
+
+func (s *insertStream) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+// Close finishes request body and waits for server response
+func (s *insertStream) Close() error {
+	err := s.buf.Flush()
+	s.pipe.Close()
+	<-s.done
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// Abort breaks request body with err and cancels request, buffered data is dropped.
+// It waits for request end and does nothing after Close
+func (s *insertStream) Abort(err error) {
+	if err == nil {
+		err = ErrInsertAborted
+	}
+	s.pipe.CloseWithError(err)
+	s.cancel()
+	<-s.done
 }
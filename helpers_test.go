@@ -1,6 +1,10 @@
 package clickhouse
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -106,3 +110,90 @@ func BenchmarkNewMultiInsert1000(b *testing.B) {
 		BuildMultiInsert("test", columns, rows)
 	}
 }
+
+type countingMarshaler struct {
+	calls *int
+}
+
+func (m countingMarshaler) MarshalClickHouse() (string, error) {
+	*m.calls++
+	return "42", nil
+}
+
+func TestBuildInsertMarshalOnce(t *testing.T) {
+	calls := 0
+	q, err := BuildInsert("test", Columns{"col1", "col2"}, Row{countingMarshaler{&calls}, "a"})
+	assert.NoError(t, err)
+	stmt, err := prepareQuery(q)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO test (col1,col2) VALUES (42,'a')", stmt)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBuildInsertUnsupported(t *testing.T) {
+	q, err := BuildMultiInsert("test", Columns{"col1", "col2"}, Rows{
+		Row{"val1", 1},
+		Row{"val2", make(chan int)},
+	})
+	assert.Equal(t, "", q.Stmt)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "row 1 column col2")
+	}
+}
+
+func TestNewInsertWriter(t *testing.T) {
+	handler := &insertHandler{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	w, err := NewInsertWriter(conn, "t", "CSV", Columns{"a", "b"})
+	assert.NoError(t, err)
+	_, err = io.Copy(w, strings.NewReader("1,x\n2,y\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "INSERT INTO t (a,b) FORMAT CSV", handler.query)
+	assert.Equal(t, "1,x\n2,y\n", string(handler.body))
+
+	w, err = NewInsertWriter(conn, "t", "TSV", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "INSERT INTO t FORMAT TSV", handler.query)
+
+	_, err = NewInsertWriter(nil, "t", "CSV", nil)
+	assert.Error(t, err)
+}
+
+func TestInsertWriter_Abort(t *testing.T) {
+	// server commits rows only when whole body is received
+	committed := make(chan bool, 2)
+	received := make(chan struct{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 1024)
+		_, err := io.ReadFull(r.Body, buf)
+		received <- struct{}{}
+		if err == nil {
+			_, err = io.Copy(io.Discard, r.Body)
+		}
+		committed <- err == nil
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	w, err := NewInsertWriter(conn, "t", "CSV", nil)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(strings.Repeat("1,x\n", 20000)))
+	assert.NoError(t, err)
+	<-received
+	w.Abort(nil)
+	assert.False(t, <-committed)
+	assert.Error(t, w.Close())
+	w.Abort(nil)
+
+	w, err = NewInsertWriter(conn, "t", "CSV", nil)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(strings.Repeat("1,x\n", 20000)))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.True(t, <-committed)
+}
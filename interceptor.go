@@ -0,0 +1,171 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kinds of requests passed to interceptors
+const (
+	CallExec   = "exec"
+	CallStream = "stream"
+	CallPing   = "ping"
+)
+
+// Call is one request to server passed through interceptor chain
+type Call struct {
+	// Kind is CallExec for Exec, Iter, ExecScan and all inserts (streamed ones too), CallStream for Stream,
+	// streaming iterators and exports, CallPing for Ping
+	Kind     string
+	Query    Query
+	Host     string
+	ReadOnly bool
+	// Start is time when request was started, time.Since(call.Start) after next handler returns is request time
+	// (for CallStream it is time until response headers are received)
+	Start time.Time
+	// Node is set for connections of Cluster with interceptors added by Cluster.Use
+	Node *NodeInfo
+	// QueueWait is time of waiting for Limiter slot, it is set before next handler of Limiter is called
+	QueueWait time.Duration
+}
+
+// SetParam sets query setting for this request only, params of original query are not changed
+func (c *Call) SetParam(name, value string) {
+	params := make(url.Values, len(c.Query.params)+1)
+	for k, v := range c.Query.params {
+		params[k] = v
+	}
+	params.Set(name, value)
+	c.Query.params = params
+}
+
+// SetHeader sets http header of this request, e.g. trace context
+func (c *Call) SetHeader(name, value string) {
+	header := make(http.Header, len(c.Query.header)+1)
+	for k, v := range c.Query.header {
+		header[k] = v
+	}
+	header.Set(name, value)
+	c.Query.header = header
+}
+
+// responseInfo is filled by transport, it is shared by copies of query
+type responseInfo struct {
+	status int
+	header http.Header
+}
+
+// ResponseHeader returns http headers of response after next handler returns,
+// it is nil if transport does not provide them
+func (c *Call) ResponseHeader() http.Header {
+	if c.Query.response == nil {
+		return nil
+	}
+	return c.Query.response.header
+}
+
+// StatusCode returns http status of response after next handler returns, it is 0 if transport does not provide it
+func (c *Call) StatusCode() int {
+	if c.Query.response == nil {
+		return 0
+	}
+	return c.Query.response.status
+}
+
+// QueryID returns id of query assigned by server or set by query_id param
+func (c *Call) QueryID() string {
+	if id := c.ResponseHeader().Get("X-ClickHouse-Query-Id"); id != "" {
+		return id
+	}
+	return c.Query.params.Get("query_id")
+}
+
+// Summary is query statistics sent by server in X-ClickHouse-Summary header
+type Summary struct {
+	ReadRows        uint64 `json:"read_rows,string"`
+	ReadBytes       uint64 `json:"read_bytes,string"`
+	WrittenRows     uint64 `json:"written_rows,string"`
+	WrittenBytes    uint64 `json:"written_bytes,string"`
+	TotalRowsToRead uint64 `json:"total_rows_to_read,string"`
+	ResultRows      uint64 `json:"result_rows,string"`
+	ResultBytes     uint64 `json:"result_bytes,string"`
+}
+
+// Summary returns statistics of query after next handler returns. Headers are sent before result,
+// so for long queries it is progress at that moment unless wait_end_of_query=1 is set
+func (c *Call) Summary() (Summary, bool) {
+	var res Summary
+	header := c.ResponseHeader().Get("X-ClickHouse-Summary")
+	if header == "" || json.Unmarshal([]byte(header), &res) != nil {
+		return Summary{}, false
+	}
+	return res, true
+}
+
+// Handler makes request, response body is closed by caller. Server exceptions are returned as *DbError
+type Handler func(call *Call) (io.ReadCloser, error)
+
+// Interceptor wraps handler, it may change call before passing it to next, return own response without
+// calling next or observe result:
+//
+//	conn.Use(func(next clickhouse.Handler) clickhouse.Handler {
+//		return func(call *clickhouse.Call) (io.ReadCloser, error) {
+//			body, err := next(call)
+//			log.Printf("%s %s: %v in %s", call.Host, call.Query.Stmt, err, time.Since(call.Start))
+//			return body, err
+//		}
+//	})
+type Interceptor func(next Handler) Handler
+
+// Use adds interceptors to all requests of connection, first added interceptor is called first.
+// It should be called before connection is used by other goroutines
+func (c *Conn) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+	handler := Handler(c.send)
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		handler = c.interceptors[i](handler)
+	}
+	c.handler = handler
+}
+
+// call passes request through interceptors
+func (c *Conn) call(kind string, q Query, readOnly bool) (io.ReadCloser, error) {
+	q.response = &responseInfo{}
+	q.kind = kind
+	call := &Call{Kind: kind, Query: q, Host: c.GetHost(), ReadOnly: readOnly, Start: time.Now()}
+	if c.handler == nil {
+		return c.send(call)
+	}
+	body, err := c.handler(call)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	if body == nil {
+		body = io.NopCloser(strings.NewReader(""))
+	}
+	return body, nil
+}
+
+// send is last handler of chain, it makes request with transport
+func (c *Conn) send(call *Call) (io.ReadCloser, error) {
+	if call.Kind == CallStream {
+		if t, ok := c.transport.(StreamTransport); ok {
+			return t.Stream(call.Host, c.GetParams().Encode(), call.Query, call.ReadOnly)
+		}
+	}
+	res, err := c.transport.Exec(call.Host, c.GetParams().Encode(), call.Query, call.ReadOnly)
+	if err == nil {
+		err = errorFromResponse(res)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(res)), nil
+}
@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConn_Use(t *testing.T) {
+	var params string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.RawQuery
+		io.WriteString(w, "1\n")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	var order []string
+	var calls []*Call
+	conn.Use(func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			order = append(order, "first")
+			calls = append(calls, call)
+			return next(call)
+		}
+	}, func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			order = append(order, "second")
+			call.SetParam("max_execution_time", "10")
+			return next(call)
+		}
+	})
+
+	q := NewQuery("SELECT 1")
+	assert.NoError(t, q.Exec(conn))
+	assert.Equal(t, []string{"first", "second"}, order)
+	assert.Contains(t, params, "max_execution_time=10")
+	assert.Empty(t, q.params.Get("max_execution_time"))
+
+	assert.NoError(t, conn.Ping())
+	body, err := q.Stream(conn)
+	assert.NoError(t, err)
+	body.Close()
+	assert.NoError(t, NewQuery("SELECT 2").Iter(conn).Error())
+	if assert.Len(t, calls, 4) {
+		assert.Equal(t, CallExec, calls[0].Kind)
+		assert.Equal(t, "SELECT 1", calls[0].Query.Stmt)
+		assert.Equal(t, conn.Host, calls[0].Host)
+		assert.False(t, calls[0].Start.IsZero())
+		assert.Equal(t, CallPing, calls[1].Kind)
+		assert.True(t, calls[1].ReadOnly)
+		assert.Equal(t, CallStream, calls[2].Kind)
+		assert.Equal(t, "SELECT 2", calls[3].Query.Stmt)
+	}
+}
+
+func TestConn_UseShortCircuit(t *testing.T) {
+	conn := NewConn(getHost(), badTransport{err: errors.New("Transport is called")})
+	conn.Use(func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			if strings.HasPrefix(call.Query.Stmt, "DROP") {
+				return nil, errors.New("DROP is not allowed")
+			}
+			return io.NopCloser(strings.NewReader("cached\t1\n")), nil
+		}
+	})
+
+	iter := NewQuery("SELECT s, n FROM t").Iter(conn)
+	var s string
+	var n int
+	assert.True(t, iter.Scan(&s, &n))
+	assert.Equal(t, "cached", s)
+	assert.Equal(t, 1, n)
+	assert.EqualError(t, NewQuery("DROP TABLE t").Exec(conn), "DROP is not allowed")
+}
+
+func TestConn_UseObserveError(t *testing.T) {
+	conn := NewConn(getHost(), getMockTransport("Code: 60, e.displayText() = DB::Exception: Table default.t doesn't exist"))
+	var observed error
+	conn.Use(func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			body, err := next(call)
+			observed = err
+			return body, err
+		}
+	})
+
+	err := NewQuery("SELECT * FROM t").Exec(conn)
+	if assert.Error(t, err) {
+		assert.Equal(t, 60, err.(*DbError).Code())
+	}
+	assert.Equal(t, err, observed)
+}
+
+func TestCluster_Use(t *testing.T) {
+	conn1 := NewConn("host1", getMockTransport("Code: 9999, Error: ..."))
+	conn2 := NewConn("host2", getMockTransport("1"))
+	cl := NewCluster(conn1, conn2)
+
+	nodes := make(map[string]*NodeInfo)
+	cl.Use(func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			nodes[call.Host] = call.Node
+			return next(call)
+		}
+	})
+
+	cl.Check()
+	if assert.NotNil(t, nodes[conn1.Host]) && assert.NotNil(t, nodes[conn2.Host]) {
+		assert.Equal(t, cl, nodes[conn1.Host].Cluster)
+		assert.False(t, nodes[conn2.Host].Active)
+	}
+
+	assert.NoError(t, NewQuery("SELECT 1").Exec(cl.ActiveConn()))
+	assert.True(t, nodes[conn2.Host].Active)
+	assert.Equal(t, 0, nodes[conn2.Host].PingErrors)
+
+	assert.Error(t, NewQuery("SELECT 1").Exec(conn1))
+	assert.False(t, nodes[conn1.Host].Active)
+	assert.Equal(t, 1, nodes[conn1.Host].PingErrors)
+
+	// node interceptor is added only once
+	cl.Use(func(next Handler) Handler { return next })
+	assert.Len(t, conn1.interceptors, 3)
+	assert.Len(t, conn2.interceptors, 3)
+}
@@ -0,0 +1,376 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const FormatJSONEachRow = "JSONEachRow"
+
+// JSONEachRowIter decodes one JSON object per row while response is received
+type JSONEachRowIter struct {
+	body   io.ReadCloser
+	dec    *json.Decoder
+	text   decoder
+	fields map[reflect.Type]map[string]int
+	err    error
+}
+
+// JSONEachRowIter makes request in JSONEachRow format. 64-bit integers are requested as JSON numbers
+// unless output_format_json_quote_64bit_integers is set for query, quoted integers are accepted by struct fields too
+func (q Query) JSONEachRowIter(conn Connector) *JSONEachRowIter {
+	if conn == nil {
+		return &JSONEachRowIter{err: errors.New("Connection pointer is nil")}
+	}
+	q.Stmt += " FORMAT " + FormatJSONEachRow
+	q = q.withDefaultParam("output_format_json_quote_64bit_integers", "0")
+
+	body, err := streamQuery(conn, q)
+	if err != nil {
+		return &JSONEachRowIter{err: err}
+	}
+	dec := json.NewDecoder(bufio.NewReaderSize(body, 64*1024))
+	dec.UseNumber()
+	return &JSONEachRowIter{
+		body:   body,
+		dec:    dec,
+		text:   decoder{loc: q.queryLocation(conn)},
+		fields: make(map[reflect.Type]map[string]int),
+	}
+}
+
+func (r *JSONEachRowIter) Error() error {
+	return r.err
+}
+
+// Close releases response body, it is closed automatically when all rows are read
+func (r *JSONEachRowIter) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// Scan decodes next row into dst. Struct fields are matched with columns by `ch` tag, `json` tag or field name
+// and strings like DateTime or UUID are parsed as in TabSeparated format.
+// Numbers in map[string]interface{} are json.Number, so 64-bit integers keep precision
+func (r *JSONEachRowIter) Scan(dst interface{}) bool {
+	if r.err != nil || r.body == nil {
+		return false
+	}
+	var row json.RawMessage
+	if err := r.dec.Decode(&row); err != nil {
+		if err != io.EOF {
+			r.setError(r.streamError(err))
+		}
+		r.Close()
+		return false
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		r.setError(fmt.Errorf("Scan expects pointer, got %T", dst))
+		return false
+	}
+	var err error
+	if v.Elem().Kind() == reflect.Struct && !isTextValue(dst) {
+		err = r.decodeStruct(v.Elem(), row)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(row))
+		dec.UseNumber()
+		err = dec.Decode(dst)
+	}
+	if err != nil {
+		r.setError(err)
+		return false
+	}
+	return true
+}
+
+// streamError returns server exception if it was sent instead of next row
+func (r *JSONEachRowIter) streamError(err error) error {
+	rest, _ := io.ReadAll(io.LimitReader(r.dec.Buffered(), 64*1024))
+	if res := errorFromResponse(strings.TrimSpace(string(rest))); res != nil {
+		return res
+	}
+	return err
+}
+
+func (r *JSONEachRowIter) decodeStruct(v reflect.Value, row json.RawMessage) error {
+	var columns map[string]json.RawMessage
+	if err := json.Unmarshal(row, &columns); err != nil {
+		return err
+	}
+	fields, ok := r.fields[v.Type()]
+	if !ok {
+		fields = make(map[string]int)
+		for _, f := range structFields(v.Type()) {
+			name := fieldColumn(v.Type().Field(f))
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				// lower case key is used for case insensitive match of field name
+				name = strings.ToLower(v.Type().Field(f).Name)
+			}
+			fields[name] = f
+		}
+		r.fields[v.Type()] = fields
+	}
+
+	for name, data := range columns {
+		f, ok := fields[name]
+		if !ok {
+			if f, ok = fields[strings.ToLower(name)]; !ok {
+				continue
+			}
+		}
+		if err := r.decodeValue(v.Field(f).Addr().Interface(), data); err != nil {
+			return fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// decodeValue unmarshals JSON value, strings which JSON cannot read into value are parsed as TabSeparated values
+func (r *JSONEachRowIter) decodeValue(dst interface{}, data json.RawMessage) error {
+	err := json.Unmarshal(data, dst)
+	if err == nil || len(data) == 0 || data[0] != '"' {
+		return err
+	}
+	var s string
+	if json.Unmarshal(data, &s) != nil {
+		return err
+	}
+	if res := r.text.unmarshalText(dst, s); res != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *JSONEachRowIter) setError(err error) {
+	r.err = err
+	r.Close()
+}
+
+// JSONEachRowEncoder writes rows as JSON objects, one per line. Rows are structs, maps with string keys or
+// json.Marshaler values. Times are written in RFC 3339 format with time zone, they are read by server
+// with date_time_input_format=best_effort
+type JSONEachRowEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewJSONEachRowEncoder(w io.Writer) *JSONEachRowEncoder {
+	return &JSONEachRowEncoder{w: w}
+}
+
+// Encode writes one row
+func (e *JSONEachRowEncoder) Encode(row interface{}) error {
+	buf, err := appendJSON(e.buf[:0], row)
+	if err != nil {
+		return err
+	}
+	e.buf = append(buf, '\n')
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+// appendJSON encodes value for JSONEachRow input, struct fields are named as in JSONEachRowIter.Scan
+func appendJSON(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case time.Time:
+		return appendJSONString(buf, v.Format(time.RFC3339Nano)), nil
+	case DateTime:
+		return appendJSONString(buf, time.Time(v).Format(time.RFC3339)), nil
+	case DateTime64:
+		if err := v.checkPrecision(); err != nil {
+			return nil, err
+		}
+		layout := "2006-01-02T15:04:05"
+		if v.Precision > 0 {
+			layout += "." + strings.Repeat("0", v.Precision)
+		}
+		return appendJSONString(buf, v.Time.Format(layout+"Z07:00")), nil
+	case Date:
+		return appendJSONString(buf, time.Time(v).Format(dateLayout)), nil
+	case Date32:
+		return appendJSONString(buf, time.Time(v).Format(dateLayout)), nil
+	case UUID:
+		return appendJSONString(buf, v.String()), nil
+	case net.IP:
+		if v == nil {
+			return append(buf, "null"...), nil
+		}
+		return appendJSONString(buf, v.String()), nil
+	case netip.Addr:
+		return appendJSONString(buf, v.Unmap().String()), nil
+	case Tuple:
+		return appendJSONArray(buf, reflect.ValueOf([]interface{}(v)))
+	case json.Marshaler:
+		data, err := v.MarshalJSON()
+		return append(buf, data...), err
+	case driver.Valuer:
+		val, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := val.([]byte); ok {
+			val = string(b)
+		}
+		return appendJSON(buf, val)
+	}
+
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, "null"...), nil
+		}
+		return appendJSON(buf, v.Elem().Interface())
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(buf, "[]"...), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is String value, not Array(UInt8)
+			return appendJSONString(buf, string(v.Bytes())), nil
+		}
+		return appendJSONArray(buf, v)
+	case reflect.Array:
+		return appendJSONArray(buf, v)
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		buf = append(buf, '{')
+		for i, key := range keys {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(appendJSONString(buf, key), ':')
+			var err error
+			if buf, err = appendJSON(buf, values[key].Interface()); err != nil {
+				return nil, fmt.Errorf("value of %s: %v", key, err)
+			}
+		}
+		return append(buf, '}'), nil
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		if len(fields) == 0 {
+			break
+		}
+		buf = append(buf, '{')
+		first := true
+		for _, f := range fields {
+			field := v.Type().Field(f)
+			name := fieldColumn(field)
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if !first {
+				buf = append(buf, ',')
+			}
+			first = false
+			buf = append(appendJSONString(buf, name), ':')
+			var err error
+			if buf, err = appendJSON(buf, v.Field(f).Interface()); err != nil {
+				return nil, fmt.Errorf("field %s: %v", field.Name, err)
+			}
+		}
+		return append(buf, '}'), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, data...), nil
+}
+
+func appendJSONArray(buf []byte, v reflect.Value) ([]byte, error) {
+	buf = append(buf, '[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var err error
+		if buf, err = appendJSON(buf, v.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("element %d: %v", i, err)
+		}
+	}
+	return append(buf, ']'), nil
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	data, _ := json.Marshal(s)
+	return append(buf, data...)
+}
+
+// JSONEachRowInsert streams rows into INSERT ... FORMAT JSONEachRow request:
+//
+//	ins, err := clickhouse.NewJSONEachRowInsert(conn, "events", nil)
+//	for _, e := range events {
+//		err = ins.Write(e)
+//	}
+//	err = ins.Close()
+type JSONEachRowInsert struct {
+	enc    *JSONEachRowEncoder
+	stream *insertStream
+}
+
+// NewJSONEachRowInsert starts insert request, cols may be empty to insert all columns.
+// Omitted columns get default values
+func NewJSONEachRowInsert(conn Connector, table string, cols Columns) (*JSONEachRowInsert, error) {
+	if conn == nil {
+		return nil, errors.New("Connection pointer is nil")
+	}
+	stmt := "INSERT INTO " + table
+	if len(cols) > 0 {
+		stmt += " (" + strings.Join(cols, ",") + ")"
+	}
+	q := NewQuery(stmt + " FORMAT " + FormatJSONEachRow)
+	q = q.withDefaultParam("date_time_input_format", "best_effort")
+
+	stream := &insertStream{}
+	stream.start(conn, q)
+	return &JSONEachRowInsert{enc: NewJSONEachRowEncoder(stream), stream: stream}, nil
+}
+
+// Write encodes and sends one row
+func (i *JSONEachRowInsert) Write(row interface{}) error {
+	return i.enc.Encode(row)
+}
+
+// Close finishes request body and waits for server response
+func (i *JSONEachRowInsert) Close() error {
+	return i.stream.Close()
+}
+
+// Abort breaks request, so server does not commit rows which were already sent
+func (i *JSONEachRowInsert) Abort(err error) {
+	i.stream.Abort(err)
+}
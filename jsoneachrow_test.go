@@ -0,0 +1,168 @@
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONEachRowIter_Scan(t *testing.T) {
+	resp := `{"id":"18446744073709551615","name":"a","created":"2020-01-02 03:04:05","day":"2020-01-02","uuid":"00112233-4455-6677-8899-aabbccddeeff","price":"1.50","tags":["x","y"],"Skipped":1}
+{"id":2,"name":"b","created":"2020-01-03 00:00:00","day":"2020-01-03","uuid":"00112233-4455-6677-8899-aabbccddeeff","price":2,"tags":[]}
+`
+	iter := NewQuery("SELECT *").JSONEachRowIter(NewConn(getHost(), getMockTransport(resp)))
+	assert.NoError(t, iter.Error())
+
+	type row struct {
+		ID      uint64 `ch:"id"`
+		Name    string `json:"name"`
+		Created time.Time
+		Day     Date
+		UUID    UUID
+		Price   Decimal
+		Tags    []string `json:"tags,omitempty"`
+	}
+	var res []row
+	var r row
+	for iter.Scan(&r) {
+		res = append(res, r)
+	}
+	assert.NoError(t, iter.Error())
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, uint64(18446744073709551615), res[0].ID)
+		assert.Equal(t, "a", res[0].Name)
+		assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), res[0].Created)
+		assert.Equal(t, "2020-01-02", time.Time(res[0].Day).Format(dateLayout))
+		assert.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", res[0].UUID.String())
+		assert.Equal(t, "1.50", res[0].Price.String())
+		assert.Equal(t, []string{"x", "y"}, res[0].Tags)
+		assert.Equal(t, uint64(2), res[1].ID)
+		assert.Equal(t, []string{}, res[1].Tags)
+	}
+}
+
+func TestJSONEachRowIter_Map(t *testing.T) {
+	resp := "{\"id\":9007199254740993,\"name\":\"a\",\"n\":null}\n"
+	iter := NewQuery("SELECT *").JSONEachRowIter(NewConn(getHost(), getMockTransport(resp)))
+	var m map[string]interface{}
+	assert.True(t, iter.Scan(&m))
+	assert.Equal(t, json.Number("9007199254740993"), m["id"])
+	assert.Equal(t, "a", m["name"])
+	assert.Nil(t, m["n"])
+	assert.False(t, iter.Scan(&m))
+	assert.NoError(t, iter.Error())
+}
+
+func TestJSONEachRowIter_Errors(t *testing.T) {
+	var m map[string]interface{}
+	iter := NewQuery("SELECT *").JSONEachRowIter(NewConn(getHost(), getMockTransport("{\"a\":1}\nCode: 241, e.displayText() = DB::Exception: Memory limit exceeded")))
+	assert.True(t, iter.Scan(&m))
+	assert.False(t, iter.Scan(&m))
+	if assert.Error(t, iter.Error()) {
+		assert.Equal(t, 241, iter.Error().(*DbError).Code())
+	}
+
+	iter = NewQuery("SELECT *").JSONEachRowIter(NewConn(getHost(), getMockTransport("{\"a\":\"x\"}\n")))
+	var r struct{ A int }
+	assert.False(t, iter.Scan(&r))
+	assert.Error(t, iter.Error())
+
+	iter = NewQuery("SELECT *").JSONEachRowIter(NewConn(getHost(), getMockTransport("{\"a\":1}\n")))
+	assert.False(t, iter.Scan(r))
+	assert.Error(t, iter.Error())
+
+	iter = NewQuery("SELECT *").JSONEachRowIter(nil)
+	assert.Error(t, iter.Error())
+	assert.False(t, iter.Scan(&m))
+}
+
+func TestJSONEachRowIter_QuoteParam(t *testing.T) {
+	var params string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.RawQuery
+		fmt.Fprint(w, "{\"a\":1}\n")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	var m map[string]interface{}
+	iter := NewQuery("SELECT 1 AS a").JSONEachRowIter(conn)
+	assert.True(t, iter.Scan(&m))
+	assert.Contains(t, params, "output_format_json_quote_64bit_integers=0")
+
+	q := NewQuery("SELECT 1 AS a")
+	q.params.Set("output_format_json_quote_64bit_integers", "1")
+	iter = q.JSONEachRowIter(conn)
+	assert.True(t, iter.Scan(&m))
+	assert.Contains(t, params, "output_format_json_quote_64bit_integers=1")
+}
+
+func TestJSONEachRowEncoder(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 123000000, time.FixedZone("MSK", 3*3600))
+	u, _ := ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	type event struct {
+		ID      uint64 `ch:"id"`
+		Name    string `json:"name,omitempty"`
+		Skip    string `json:"-"`
+		Created time.Time
+		Day     Date
+		At      DateTime64
+		UUID    UUID
+		Price   Decimal
+		Tags    []string
+		Attrs   map[string]int
+		Pair    Tuple
+		Raw     []byte
+		Note    *string
+		private int
+	}
+	buf := &bytes.Buffer{}
+	enc := NewJSONEachRowEncoder(buf)
+	assert.NoError(t, enc.Encode(event{
+		ID: 1 << 63, Name: "a", Skip: "x", Created: tm, Day: Date(tm), At: DateTime64{Time: tm, Precision: 3},
+		UUID: u, Price: NewDecimal(150, 2), Tags: []string{"t"}, Attrs: map[string]int{"b": 2, "a": 1},
+		Pair: Tuple{"p", 1}, Raw: []byte("r"),
+	}))
+	assert.NoError(t, enc.Encode(map[string]interface{}{"id": 2, "ip": nil}))
+	assert.Equal(t, `{"id":9223372036854775808,"name":"a","Created":"2020-01-02T03:04:05.123+03:00","Day":"2020-01-02",`+
+		`"At":"2020-01-02T03:04:05.123+03:00","UUID":"00112233-4455-6677-8899-aabbccddeeff","Price":1.50,"Tags":["t"],`+
+		`"Attrs":{"a":1,"b":2},"Pair":["p",1],"Raw":"r","Note":null}`+"\n"+
+		`{"id":2,"ip":null}`+"\n", buf.String())
+
+	assert.Error(t, enc.Encode(map[string]interface{}{"f": func() {}}))
+}
+
+func TestJSONEachRowInsert(t *testing.T) {
+	var query, params string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		params = r.URL.RawQuery
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	ins, err := NewJSONEachRowInsert(conn, "events", Columns{"id", "name"})
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Write(struct {
+		ID   int    `ch:"id"`
+		Name string `ch:"name"`
+	}{1, "a"}))
+	assert.NoError(t, ins.Write(map[string]interface{}{"id": 2}))
+	assert.NoError(t, ins.Close())
+
+	assert.Equal(t, "INSERT INTO events (id,name) FORMAT JSONEachRow", query)
+	assert.Contains(t, params, "date_time_input_format=best_effort")
+	assert.Equal(t, "{\"id\":1,\"name\":\"a\"}\n{\"id\":2}\n", string(body))
+
+	_, err = NewJSONEachRowInsert(nil, "events", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package clickhouse
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrQueueTimeout is returned when request waited for Limiter pool longer than its QueueTimeout
+var ErrQueueTimeout = errors.New("Request waited in limiter queue too long")
+
+// PoolOptions control one pool of Limiter
+type PoolOptions struct {
+	// MaxInFlight is amount of requests of pool which are sent at once, it should be positive
+	MaxInFlight int
+	// QueueTimeout limits waiting for free slot, zero waits until query context is done
+	QueueTimeout time.Duration
+}
+
+// QueueMetrics receives queue waits of Limiter, chprom.Collector implements it
+type QueueMetrics interface {
+	// RequestQueued is called when request gets slot of pool or fails waiting for it
+	RequestQueued(pool string, wait time.Duration, err error)
+}
+
+// Limiter limits amount of concurrent requests in named pools, requests waiting for slot are ordered by priority:
+//
+//	limiter := clickhouse.NewLimiter()
+//	limiter.SetPool("ingest", clickhouse.PoolOptions{MaxInFlight: 20})
+//	limiter.SetPool("reporting", clickhouse.PoolOptions{MaxInFlight: 5, QueueTimeout: 10 * time.Second})
+//	conn.Use(limiter.Interceptor())
+//
+//	q := clickhouse.NewQuery("SELECT ...")
+//	q.SetPool("reporting", 10)
+//
+// Limiter added to several connections or Cluster limits their requests together.
+// Queries of unknown pools and pings are not limited, streaming request keeps slot until body is closed
+type Limiter struct {
+	mx      sync.Mutex
+	pools   map[string]*pool
+	metrics QueueMetrics
+}
+
+type pool struct {
+	PoolOptions
+	inFlight int
+	queue    waitQueue
+	seq      uint64
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{pools: make(map[string]*pool)}
+}
+
+// SetPool adds pool or changes its options, pool "" is used for queries without SetPool
+func (l *Limiter) SetPool(name string, opts PoolOptions) error {
+	if opts.MaxInFlight <= 0 {
+		return fmt.Errorf("MaxInFlight of pool %q is %d, it should be positive", name, opts.MaxInFlight)
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	p, ok := l.pools[name]
+	if !ok {
+		p = &pool{}
+		l.pools[name] = p
+	}
+	p.PoolOptions = opts
+	p.dispatch()
+	return nil
+}
+
+// SetMetrics sets receiver of queue waits
+func (l *Limiter) SetMetrics(m QueueMetrics) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.metrics = m
+}
+
+// InFlight returns amount of sent requests and requests waiting in queue of pool
+func (l *Limiter) InFlight(name string) (inFlight, queued int) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if p, ok := l.pools[name]; ok {
+		return p.inFlight, p.queue.Len()
+	}
+	return 0, 0
+}
+
+// SetPool sets Limiter pool of query and its priority in pool queue, higher priority is served first
+func (q *Query) SetPool(name string, priority int) {
+	q.pool = name
+	q.priority = priority
+}
+
+// Interceptor returns interceptor which waits for pool slot before request, wait time is set to Call.QueueWait
+func (l *Limiter) Interceptor() Interceptor {
+	return func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			if call.Kind == CallPing {
+				return next(call)
+			}
+			l.mx.Lock()
+			p, ok := l.pools[call.Query.pool]
+			metrics := l.metrics
+			l.mx.Unlock()
+			if !ok {
+				return next(call)
+			}
+
+			start := time.Now()
+			err := l.acquire(call, p)
+			call.QueueWait = time.Since(start)
+			if metrics != nil {
+				metrics.RequestQueued(call.Query.pool, call.QueueWait, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			body, err := next(call)
+			if err != nil || body == nil {
+				l.release(p)
+				return body, err
+			}
+			return &releaseBody{ReadCloser: body, release: func() { l.release(p) }}, nil
+		}
+	}
+}
+
+func (l *Limiter) acquire(call *Call, p *pool) error {
+	l.mx.Lock()
+	if p.inFlight < p.MaxInFlight && p.queue.Len() == 0 {
+		p.inFlight++
+		l.mx.Unlock()
+		return nil
+	}
+	p.seq++
+	w := &waiter{priority: call.Query.priority, seq: p.seq, ready: make(chan struct{})}
+	heap.Push(&p.queue, w)
+	queueTimeout := p.QueueTimeout
+	l.mx.Unlock()
+
+	var timeout <-chan time.Time
+	if queueTimeout > 0 {
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ctx := call.Query.Context()
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if w.index >= 0 {
+		heap.Remove(&p.queue, w.index)
+		return err
+	}
+	// slot was given while waiting was stopped, so it is passed to next waiter
+	p.inFlight--
+	p.dispatch()
+	return err
+}
+
+func (l *Limiter) release(p *pool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	p.inFlight--
+	p.dispatch()
+}
+
+// dispatch gives free slots to waiters with highest priority
+func (p *pool) dispatch() {
+	for p.inFlight < p.MaxInFlight && p.queue.Len() > 0 {
+		w := heap.Pop(&p.queue).(*waiter)
+		p.inFlight++
+		close(w.ready)
+	}
+}
+
+// releaseBody frees pool slot when response is closed
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// waitQueue is heap of waiters, higher priority and then earlier ones go first
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
@@ -0,0 +1,199 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockTransport answers requests when they are released
+type blockTransport struct {
+	started chan string
+	release chan struct{}
+}
+
+func newBlockTransport() *blockTransport {
+	return &blockTransport{started: make(chan string, 100), release: make(chan struct{})}
+}
+
+func (t *blockTransport) Exec(host, params string, q Query, readOnly bool) (string, error) {
+	t.started <- q.Stmt
+	<-t.release
+	return "1\n", nil
+}
+
+type testQueueMetrics struct {
+	mx    sync.Mutex
+	waits map[string]int
+	errs  int
+}
+
+func (m *testQueueMetrics) RequestQueued(pool string, wait time.Duration, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.waits[pool]++
+	if err != nil {
+		m.errs++
+	}
+}
+
+func TestLimiter_Priority(t *testing.T) {
+	tr := newBlockTransport()
+	conn := NewConn(getHost(), tr)
+	limiter := NewLimiter()
+	limiter.SetPool("reporting", PoolOptions{MaxInFlight: 1})
+	metrics := &testQueueMetrics{waits: make(map[string]int)}
+	limiter.SetMetrics(metrics)
+	var waits sync.Map
+	conn.Use(func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			body, err := next(call)
+			waits.Store(call.Query.Stmt, call.QueueWait)
+			return body, err
+		}
+	}, limiter.Interceptor())
+
+	exec := func(stmt string, priority int, wg *sync.WaitGroup) {
+		q := NewQuery(stmt)
+		q.SetPool("reporting", priority)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, q.Exec(conn))
+		}()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	exec("first", 0, &wg)
+	assert.Equal(t, "first", <-tr.started)
+	exec("low", 1, &wg)
+	waitQueued(t, limiter, "reporting", 1)
+	exec("high", 5, &wg)
+	waitQueued(t, limiter, "reporting", 2)
+	exec("low2", 1, &wg)
+	waitQueued(t, limiter, "reporting", 3)
+
+	// requests of unknown pool and pings are not limited
+	go conn.Ping()
+	assert.Equal(t, "SELECT+1", <-tr.started)
+	tr.release <- struct{}{}
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		tr.release <- struct{}{}
+		if i < 3 {
+			order = append(order, <-tr.started)
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"high", "low", "low2"}, order)
+	inFlight, queued := limiter.InFlight("reporting")
+	assert.Equal(t, 0, inFlight)
+	assert.Equal(t, 0, queued)
+
+	wait, _ := waits.Load("low2")
+	assert.True(t, wait.(time.Duration) > 0)
+	assert.Equal(t, 4, metrics.waits["reporting"])
+}
+
+func TestLimiter_Timeout(t *testing.T) {
+	tr := newBlockTransport()
+	conn := NewConn(getHost(), tr)
+	limiter := NewLimiter()
+	limiter.SetPool("", PoolOptions{MaxInFlight: 1, QueueTimeout: 20 * time.Millisecond})
+	conn.Use(limiter.Interceptor())
+
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, NewQuery("first").Exec(conn))
+		close(done)
+	}()
+	<-tr.started
+
+	assert.Equal(t, ErrQueueTimeout, NewQuery("second").Exec(conn))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := NewQuery("third")
+	q.SetContext(ctx)
+	go func() {
+		waitQueued(t, limiter, "", 1)
+		cancel()
+	}()
+	assert.Equal(t, context.Canceled, q.Exec(conn))
+
+	_, queued := limiter.InFlight("")
+	assert.Equal(t, 0, queued)
+	tr.release <- struct{}{}
+	<-done
+	inFlight, _ := limiter.InFlight("")
+	assert.Equal(t, 0, inFlight)
+}
+
+func TestLimiter_Stream(t *testing.T) {
+	conn := NewConn(getHost(), getMockTransport("1\n"))
+	limiter := NewLimiter()
+	limiter.SetPool("", PoolOptions{MaxInFlight: 1})
+	conn.Use(limiter.Interceptor())
+
+	body, err := NewQuery("SELECT 1").Stream(conn)
+	assert.NoError(t, err)
+	inFlight, _ := limiter.InFlight("")
+	assert.Equal(t, 1, inFlight)
+	body.Close()
+	body.Close()
+	inFlight, _ = limiter.InFlight("")
+	assert.Equal(t, 0, inFlight)
+
+	// more slots are given to waiting requests
+	body, err = NewQuery("SELECT 1").Stream(conn)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- NewQuery("SELECT 1").Exec(conn) }()
+	waitQueued(t, limiter, "", 1)
+	assert.NoError(t, limiter.SetPool("", PoolOptions{MaxInFlight: 2}))
+	assert.NoError(t, <-done)
+	body.Close()
+}
+
+func TestLimiter_SetPool(t *testing.T) {
+	limiter := NewLimiter()
+	assert.Error(t, limiter.SetPool("", PoolOptions{MaxInFlight: 0}))
+	assert.Error(t, limiter.SetPool("reporting", PoolOptions{MaxInFlight: -1}))
+	assert.NoError(t, limiter.SetPool("reporting", PoolOptions{MaxInFlight: 1}))
+
+	// unknown pools are not limited
+	conn := NewConn(getHost(), getMockTransport("1\n"))
+	conn.Use(limiter.Interceptor())
+	assert.NoError(t, NewQuery("SELECT 1").Exec(conn))
+	inFlight, queued := limiter.InFlight("")
+	assert.Equal(t, 0, inFlight+queued)
+
+	// metrics can be changed while requests are sent
+	metrics := &testQueueMetrics{waits: make(map[string]int)}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := NewQuery("SELECT 1")
+			q.SetPool("reporting", 0)
+			assert.NoError(t, q.Exec(conn))
+		}()
+	}
+	limiter.SetMetrics(metrics)
+	wg.Wait()
+}
+
+func waitQueued(t *testing.T, l *Limiter, pool string, n int) {
+	for i := 0; i < 1000; i++ {
+		if _, queued := l.InFlight(pool); queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Pool %q has no %d queued requests", pool, n)
+}
@@ -0,0 +1,160 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+)
+
+type literalKind int
+
+const (
+	// literalScalar is unquoted token like number or NULL
+	literalScalar literalKind = iota
+	// literalString is quoted string, text is already unescaped
+	literalString
+	literalArray
+	literalTuple
+	// literalMap items are stored as key, value pairs one by one
+	literalMap
+)
+
+// literal is parsed value of ClickHouse text representation: [1,2], ('a',1), {'k':[1]} etc.
+type literal struct {
+	kind  literalKind
+	text  string
+	items []literal
+}
+
+func (l literal) isNull() bool {
+	return l.kind == literalScalar && l.text == "NULL"
+}
+
+func (k literalKind) String() string {
+	switch k {
+	case literalString:
+		return "string"
+	case literalArray:
+		return "array"
+	case literalTuple:
+		return "tuple"
+	case literalMap:
+		return "map"
+	}
+	return "scalar"
+}
+
+// parseLiteral tokenizes array, tuple and map literals with any nesting level
+func parseLiteral(s string) (literal, error) {
+	p := literalParser{s: s}
+	l, err := p.parse()
+	if err != nil {
+		return l, err
+	}
+	p.skipSpaces()
+	if p.pos != len(p.s) {
+		return l, p.errorf("unexpected trailing data")
+	}
+	return l, nil
+}
+
+type literalParser struct {
+	s   string
+	pos int
+}
+
+func (p *literalParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Cannot parse %q at position %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *literalParser) skipSpaces() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\n' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *literalParser) parse() (literal, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.s) {
+		return literal{}, p.errorf("unexpected end of data")
+	}
+	switch p.s[p.pos] {
+	case '[':
+		return p.parseList(literalArray, ']')
+	case '(':
+		return p.parseList(literalTuple, ')')
+	case '{':
+		return p.parseList(literalMap, '}')
+	case '\'':
+		return p.parseString()
+	}
+	return p.parseScalar()
+}
+
+func (p *literalParser) parseString() (literal, error) {
+	start := p.pos + 1
+	for i := start; i < len(p.s); i++ {
+		switch p.s[i] {
+		case '\\':
+			i++
+		case '\'':
+			p.pos = i + 1
+			return literal{kind: literalString, text: unescapeTSV(p.s[start:i])}, nil
+		}
+	}
+	return literal{}, p.errorf("unterminated string")
+}
+
+func (p *literalParser) parseScalar() (literal, error) {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(",:[](){}' \t\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return literal{}, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return literal{kind: literalScalar, text: p.s[start:p.pos]}, nil
+}
+
+func (p *literalParser) parseList(kind literalKind, end byte) (literal, error) {
+	res := literal{kind: kind, items: []literal{}}
+	p.pos++
+	p.skipSpaces()
+	if p.pos < len(p.s) && p.s[p.pos] == end {
+		p.pos++
+		return res, nil
+	}
+	for {
+		item, err := p.parse()
+		if err != nil {
+			return res, err
+		}
+		res.items = append(res.items, item)
+		p.skipSpaces()
+
+		if kind == literalMap {
+			if p.pos >= len(p.s) || p.s[p.pos] != ':' {
+				return res, p.errorf("expected ':'")
+			}
+			p.pos++
+			item, err = p.parse()
+			if err != nil {
+				return res, err
+			}
+			res.items = append(res.items, item)
+			p.skipSpaces()
+		}
+
+		if p.pos >= len(p.s) {
+			return res, p.errorf("expected %q", end)
+		}
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+		case end:
+			p.pos++
+			return res, nil
+		default:
+			return res, p.errorf("expected ',' or %q", end)
+		}
+	}
+}
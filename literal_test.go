@@ -0,0 +1,43 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLiteral(t *testing.T) {
+	l, err := parseLiteral("['a,b','c\\'d',NULL]")
+	assert.NoError(t, err)
+	assert.Equal(t, literal{kind: literalArray, items: []literal{
+		{kind: literalString, text: "a,b"},
+		{kind: literalString, text: "c'd"},
+		{kind: literalScalar, text: "NULL"},
+	}}, l)
+
+	l, err = parseLiteral("[[1,2], []]")
+	assert.NoError(t, err)
+	assert.Equal(t, literal{kind: literalArray, items: []literal{
+		{kind: literalArray, items: []literal{{kind: literalScalar, text: "1"}, {kind: literalScalar, text: "2"}}},
+		{kind: literalArray, items: []literal{}},
+	}}, l)
+
+	l, err = parseLiteral("[('a',1),('b]',-2.5)]")
+	assert.NoError(t, err)
+	assert.Equal(t, literalTuple, l.items[1].kind)
+	assert.Equal(t, "b]", l.items[1].items[0].text)
+	assert.Equal(t, "-2.5", l.items[1].items[1].text)
+
+	l, err = parseLiteral("{'k1':[1],'k:2':[]}")
+	assert.NoError(t, err)
+	assert.Equal(t, literalMap, l.kind)
+	assert.Len(t, l.items, 4)
+	assert.Equal(t, "k:2", l.items[2].text)
+}
+
+func TestParseLiteralErrors(t *testing.T) {
+	for _, s := range []string{"", "[", "[1,2", "['a]", "[1 2]", "{'a'}", "[1],", "[,]"} {
+		_, err := parseLiteral(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,151 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Logger is implemented by *slog.Logger
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// LogOptions control LogQueries interceptor
+type LogOptions struct {
+	// SlowThreshold is minimal duration of logged successful query, zero logs all queries
+	SlowThreshold time.Duration
+	// Level of successful queries, failed queries are logged with slog.LevelError
+	Level slog.Level
+	// MaxArgLength limits length of each bound :value: argument, default is 64 bytes
+	MaxArgLength int
+	// SensitiveParams are names of settings which values are redacted, password is always redacted
+	SensitiveParams []string
+}
+
+const (
+	defaultMaxArgLength = 64
+	redacted            = "[REDACTED]"
+)
+
+// LogQueries returns interceptor which logs slow and failed queries:
+//
+//	conn.Use(clickhouse.LogQueries(slog.Default(), clickhouse.LogOptions{SlowThreshold: time.Second}))
+//
+// Record has statement with bound arguments, host, kind, duration, query_id, params of query and error.
+// Duration of streaming request is time until response headers are received
+func LogQueries(logger Logger, opts LogOptions) Interceptor {
+	if opts.MaxArgLength <= 0 {
+		opts.MaxArgLength = defaultMaxArgLength
+	}
+	sensitive := map[string]bool{"password": true}
+	for _, name := range opts.SensitiveParams {
+		sensitive[name] = true
+	}
+
+	return func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			body, err := next(call)
+			elapsed := time.Since(call.Start)
+			if err == nil && elapsed < opts.SlowThreshold {
+				return body, err
+			}
+
+			args := []any{
+				slog.String("query", logStatement(call.Query, opts.MaxArgLength)),
+				slog.String("host", call.Host),
+				slog.String("kind", call.Kind),
+				slog.Duration("duration", elapsed),
+			}
+			if id := call.QueryID(); id != "" {
+				args = append(args, slog.String("query_id", id))
+			}
+			if call.QueueWait > 0 {
+				args = append(args, slog.Duration("queue_wait", call.QueueWait))
+			}
+			if len(call.Query.params) > 0 {
+				args = append(args, slog.Any("params", redactParams(call.Query.params, sensitive)))
+			}
+			if err == nil {
+				logger.Log(call.Query.Context(), opts.Level, "clickhouse query", args...)
+				return body, err
+			}
+
+			args = append(args, slog.String("error", redactError(err, sensitive)))
+			var dbErr *DbError
+			if errors.As(err, &dbErr) {
+				args = append(args, slog.Int("code", dbErr.Code()))
+			}
+			logger.Log(call.Query.Context(), slog.LevelError, "clickhouse query failed", args...)
+			return body, err
+		}
+	}
+}
+
+// logStatement binds :value: arguments of query, long values are truncated
+func logStatement(q Query, maxLength int) string {
+	if len(q.args) == 0 {
+		return q.Stmt
+	}
+	var b strings.Builder
+	stmt := q.Stmt
+	for i := 0; ; i++ {
+		pos := strings.Index(stmt, ":value:")
+		if pos < 0 || i >= len(q.args) {
+			b.WriteString(stmt)
+			return b.String()
+		}
+		b.WriteString(stmt[:pos])
+		val, err := marshal(q.args[i])
+		if err != nil {
+			val = "?"
+		}
+		if len(val) > maxLength {
+			cut := maxLength
+			for cut > 0 && !utf8.RuneStart(val[cut]) {
+				cut--
+			}
+			val = val[:cut] + "..."
+		}
+		b.WriteString(val)
+		stmt = stmt[pos+len(":value:"):]
+	}
+}
+
+func redactParams(params url.Values, sensitive map[string]bool) map[string]string {
+	res := make(map[string]string, len(params))
+	for name := range params {
+		if sensitive[name] {
+			res[name] = redacted
+		} else {
+			res[name] = params.Get(name)
+		}
+	}
+	return res
+}
+
+// redactError removes sensitive params and statement from url of http client errors
+func redactError(err error, sensitive map[string]bool) string {
+	msg := err.Error()
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return msg
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return strings.Replace(msg, urlErr.URL, redacted, 1)
+	}
+	params := u.Query()
+	for name := range params {
+		if sensitive[name] || name == "query" {
+			params.Set(name, redacted)
+		}
+	}
+	u.RawQuery = params.Encode()
+	return strings.Replace(msg, urlErr.URL, u.String(), 1)
+}
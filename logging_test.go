@@ -0,0 +1,98 @@
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func logRecords(buf *bytes.Buffer) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		json.Unmarshal([]byte(line), &rec)
+		res = append(res, rec)
+	}
+	return res
+}
+
+func TestLogQueries(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	conn := NewConn(getHost(), waitTransport{response: "1\n", duration: 20 * time.Millisecond})
+	conn.Use(LogQueries(logger, LogOptions{SlowThreshold: 10 * time.Millisecond, MaxArgLength: 5, SensitiveParams: []string{"quota_key"}}))
+
+	q := NewQuery("SELECT * FROM t WHERE name = :value: AND id = :value:", "Ünïcödé secret", 1)
+	q.params.Set("password", "pass")
+	q.params.Set("quota_key", "key")
+	q.params.Set("query_id", "qid-1")
+	q.params.Set("max_threads", "2")
+	assert.NoError(t, q.Exec(conn))
+
+	records := logRecords(buf)
+	if assert.Len(t, records, 1) {
+		rec := records[0]
+		assert.Equal(t, "INFO", rec["level"])
+		assert.Equal(t, "clickhouse query", rec["msg"])
+		assert.Equal(t, "SELECT * FROM t WHERE name = 'Ün... AND id = 1", rec["query"])
+		assert.Equal(t, conn.Host, rec["host"])
+		assert.Equal(t, CallExec, rec["kind"])
+		assert.Equal(t, "qid-1", rec["query_id"])
+		assert.Equal(t, map[string]interface{}{
+			"password": "[REDACTED]", "quota_key": "[REDACTED]", "query_id": "qid-1", "max_threads": "2",
+		}, rec["params"])
+	}
+	assert.NotContains(t, buf.String(), "secret")
+	assert.NotContains(t, buf.String(), "pass\"")
+
+	buf.Reset()
+	conn = NewConn(getHost(), getMockTransport("1\n"))
+	conn.Use(LogQueries(logger, LogOptions{SlowThreshold: time.Second}))
+	assert.NoError(t, NewQuery("SELECT 1").Exec(conn))
+	assert.Empty(t, buf.String())
+
+	conn = NewConn(getHost(), getMockTransport("Code: 60, e.displayText() = DB::Exception: Table default.t doesn't exist"))
+	conn.Use(LogQueries(logger, LogOptions{SlowThreshold: time.Second}))
+	assert.Error(t, NewQuery("SELECT * FROM t").Exec(conn))
+	records = logRecords(buf)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "ERROR", records[0]["level"])
+		assert.Equal(t, "clickhouse query failed", records[0]["msg"])
+		assert.Equal(t, float64(60), records[0]["code"])
+		assert.Contains(t, records[0]["error"], "doesn't exist")
+	}
+}
+
+func TestLogQueries_RedactError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	err := &url.Error{Op: "Get", URL: "http://host/?query=SELECT+%27secret%27&user=u&password=pass", Err: errors.New("connection refused")}
+	conn := NewAuthConn(getHost(), badTransport{err: err}, "u", "pass")
+	conn.Use(LogQueries(logger, LogOptions{}))
+
+	assert.Error(t, conn.Ping())
+	assert.NotContains(t, buf.String(), "=pass")
+	assert.Contains(t, buf.String(), "password=%5BREDACTED%5D")
+	assert.NotContains(t, buf.String(), "secret")
+	assert.Contains(t, buf.String(), "connection refused")
+	assert.Contains(t, buf.String(), "user=u")
+}
+
+func TestLogStatement(t *testing.T) {
+	q := NewQuery("SELECT :value:, :value:", "a")
+	assert.Equal(t, "SELECT 'a', :value:", logStatement(q, 10))
+	q = NewQuery("SELECT :value:", func() {})
+	assert.Equal(t, "SELECT ?", logStatement(q, 10))
+	assert.Equal(t, "SELECT 1", logStatement(NewQuery("SELECT 1"), 10))
+}
@@ -1,238 +1,714 @@
 package clickhouse
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-func escape(s string) string {
-	s = strings.Replace(s, `\`, `\\`, -1)
-	s = strings.Replace(s, `'`, `\'`, -1)
-	return s
+// EscapeTSV escapes string for TabSeparated data, e.g. external tables or FORMAT TabSeparated inserts.
+// It follows ClickHouse escaping rules, so tabs and newlines inside values do not break rows and columns.
+func EscapeTSV(s string) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		esc := tsvEscapes[c]
+		if esc == 0 {
+			if buf != nil {
+				buf = append(buf, c)
+			}
+			continue
+		}
+		if buf == nil {
+			buf = make([]byte, 0, len(s)+8)
+			buf = append(buf, s[:i]...)
+		}
+		buf = append(buf, '\\', esc)
+	}
+	if buf == nil {
+		return s
+	}
+	return string(buf)
+}
+
+var tsvEscapes = [256]byte{
+	'\b': 'b',
+	'\f': 'f',
+	'\n': 'n',
+	'\r': 'r',
+	'\t': 't',
+	0:    '0',
+	'\'': '\'',
+	'\\': '\\',
+}
+
+// unescapeTSV reverts escaping of TabSeparated value, also used for quoted strings inside arrays
+func unescapeTSV(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			buf = append(buf, c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case '0':
+			buf = append(buf, 0)
+		case 'a':
+			buf = append(buf, '\a')
+		case 'v':
+			buf = append(buf, '\v')
+		case 'x':
+			if i+2 < len(s) {
+				if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					buf = append(buf, byte(b))
+					i += 2
+					continue
+				}
+			}
+			buf = append(buf, c)
+		default:
+			// \\, \' and unknown sequences stand for the character itself
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
+
+// quoteString makes SQL string literal. Only backslash, quote and zero byte need escaping,
+// other bytes (including control chars and invalid UTF-8) are kept as is and read back by server exactly.
+func quoteString(s string) string {
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, '\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '\'':
+			buf = append(buf, '\\', c)
+		case 0:
+			buf = append(buf, '\\', '0')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	buf = append(buf, '\'')
+	return string(buf)
+}
+
+// ClickHouseMarshaler is implemented by types that render themselves as SQL literal, e.g. 'RU' or toDecimal64('1.5', 2)
+type ClickHouseMarshaler interface {
+	MarshalClickHouse() (string, error)
 }
 
-func unescape(s string) string {
-	s = strings.Replace(s, `\\`, `\`, -1)
-	s = strings.Replace(s, `\'`, `'`, -1)
-	return s
+// ClickHouseUnmarshaler is implemented by types that read themselves from column text.
+// Escaping of TabSeparated format is already removed from data, NULL is passed as \N
+type ClickHouseUnmarshaler interface {
+	UnmarshalClickHouse(data string) error
 }
 
-func isArray(s string) bool {
-	return strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]")
+// decoder reads TabSeparated columns, loc is used for dates and times that come without time zone
+type decoder struct {
+	loc *time.Location
 }
 
-func isEmptyArray(s string) bool {
-	return s == "[]"
+var nullLiteral = literal{kind: literalScalar, text: "NULL"}
+
+func unmarshal(value interface{}, data string) error {
+	return decoder{loc: time.UTC}.unmarshal(value, data)
+}
+
+// unmarshal reads TabSeparated column into value, it should be a pointer
+func (d decoder) unmarshal(value interface{}, data string) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("Type %T is not supported for unmarshaling", value)
+	}
+	if data == `\N` {
+		return d.decodeLiteral(v.Elem(), nullLiteral)
+	}
+	if isTextValue(value) || !isComposite(v.Elem()) {
+		return d.unmarshalText(value, unescapeTSV(data))
+	}
+
+	l, err := parseLiteral(data)
+	if err != nil {
+		return fmt.Errorf("Column data is not of type %s: %v", v.Elem().Type(), err)
+	}
+	if l.kind != literalArray && l.kind != literalTuple && l.kind != literalMap {
+		return fmt.Errorf("Column data is not of type %s: got %s", v.Elem().Type(), l.kind)
+	}
+	return d.decodeLiteral(v.Elem(), l)
 }
 
-func splitStringToItems(s string) []string {
-	return strings.Split(string(s[1:len(s)-1]), ",")
+// isTextValue reports whether value is read from single text value even if it is slice or struct
+func isTextValue(value interface{}) bool {
+	switch value.(type) {
+	case ClickHouseUnmarshaler, sql.Scanner, encoding.TextUnmarshaler,
+		*[]byte, *time.Time, *Date, *Date32, *DateTime, *DateTime64,
+		*Decimal, *big.Int, *UUID, *net.IP, *netip.Addr, *VisitParamsString:
+		return true
+	}
+	return false
 }
 
-func splitStringToItemsArray(s string) []string {
-	return strings.Split(string(s[2:len(s)-2]), "','")
+func isComposite(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return true
+	}
+	return false
 }
 
-func unmarshal(value interface{}, data string) (err error) {
+// unmarshalText reads scalar value, data has no escape sequences
+func (d decoder) unmarshalText(value interface{}, data string) (err error) {
 	var m interface{}
 	switch v := value.(type) {
+	case ClickHouseUnmarshaler:
+		return v.UnmarshalClickHouse(data)
 	case *int:
 		*v, err = strconv.Atoi(data)
-		return err
 	case *int8:
 		m, err = strconv.ParseInt(data, 10, 8)
-		*v = int8(m.(int64))
+		if err == nil {
+			*v = int8(m.(int64))
+		}
 	case *int16:
 		m, err = strconv.ParseInt(data, 10, 16)
-		*v = int16(m.(int64))
+		if err == nil {
+			*v = int16(m.(int64))
+		}
 	case *int32:
 		m, err = strconv.ParseInt(data, 10, 32)
-		*v = int32(m.(int64))
+		if err == nil {
+			*v = int32(m.(int64))
+		}
 	case *int64:
 		*v, err = strconv.ParseInt(data, 10, 64)
+	case *uint:
+		m, err = strconv.ParseUint(data, 10, strconv.IntSize)
+		if err == nil {
+			*v = uint(m.(uint64))
+		}
+	case *uint8:
+		m, err = strconv.ParseUint(data, 10, 8)
+		if err == nil {
+			*v = uint8(m.(uint64))
+		}
+	case *uint16:
+		m, err = strconv.ParseUint(data, 10, 16)
+		if err == nil {
+			*v = uint16(m.(uint64))
+		}
+	case *uint32:
+		m, err = strconv.ParseUint(data, 10, 32)
+		if err == nil {
+			*v = uint32(m.(uint64))
+		}
+	case *uint64:
+		*v, err = strconv.ParseUint(data, 10, 64)
+	case *big.Int:
+		// parse into temporary value, failed SetString leaves its receiver undefined
+		b, ok := new(big.Int).SetString(data, 10)
+		if !ok {
+			return fmt.Errorf("Cannot parse %q as big integer", data)
+		}
+		v.Set(b)
+	case *Decimal:
+		var dec Decimal
+		dec, err = ParseDecimal(data)
+		if err == nil {
+			*v = dec
+		}
 	case *float32:
 		m, err = strconv.ParseFloat(data, 32)
-		*v = float32(m.(float64))
+		if err == nil {
+			*v = float32(m.(float64))
+		}
 	case *float64:
-		m, err = strconv.ParseFloat(data, 64)
-		*v = m.(float64)
+		*v, err = strconv.ParseFloat(data, 64)
+	case *bool:
+		*v, err = strconv.ParseBool(data)
 	case *string:
-		*v = unescape(data)
+		*v = data
+	case *[]byte:
+		*v = []byte(data)
 	case *time.Time:
-		*v, err = time.ParseInLocation("2006-01-02 15:04:05", data, time.UTC)
-	case *[]int:
-		if !isArray(data) {
-			//noinspection GoPlaceholderCount
-			return fmt.Errorf("Column data is not of type []int")
+		m, err = parseTime(data, d.loc)
+		if err == nil {
+			*v = m.(time.Time)
 		}
-		if isEmptyArray(data) {
-			*v = []int{}
-			return
+	case *Date:
+		m, err = parseTime(data, d.loc)
+		if err == nil {
+			*v = Date(m.(time.Time))
 		}
-
-		items := splitStringToItems(data)
-		res := make([]int, len(items))
-		for i := 0; i < len(items); i++ {
-			unmarshal(&res[i], items[i])
+	case *Date32:
+		m, err = parseTime(data, d.loc)
+		if err == nil {
+			*v = Date32(m.(time.Time))
 		}
-
-		*v = res
-	case *[]float32:
-		if !isArray(data) {
-			//noinspection GoPlaceholderCount
-			return fmt.Errorf("Column data is not of type []int")
+	case *DateTime:
+		m, err = parseDateTime(data, d.loc)
+		if err == nil {
+			*v = DateTime(m.(time.Time))
 		}
-		if isEmptyArray(data) {
-			*v = []float32{}
-			return
+	case *DateTime64:
+		m, err = parseTime(data, d.loc)
+		if err == nil {
+			*v = DateTime64{Time: m.(time.Time), Precision: fractionDigits(data)}
 		}
+	case *VisitParamsString:
+		err = json.Unmarshal([]byte(data), v)
+	case *UUID:
+		*v, err = ParseUUID(data)
+	case *net.IP:
+		*v, err = parseIP(data)
+	case *netip.Addr:
+		*v, err = netip.ParseAddr(data)
+	case sql.Scanner:
+		err = v.Scan(data)
+	case encoding.TextUnmarshaler:
+		err = v.UnmarshalText([]byte(data))
+	default:
+		return d.unmarshalKind(reflect.ValueOf(value).Elem(), data)
+	}
 
-		items := splitStringToItems(data)
-		res := make([]float32, len(items))
-		for i := 0; i < len(items); i++ {
-			unmarshal(&res[i], items[i])
-		}
+	return err
+}
 
-		*v = res
-	case *[]string:
-		if !isArray(data) {
-			//noinspection GoPlaceholderCount
-			return fmt.Errorf("Column data is not of type []string")
+// unmarshalKind reads named types like `type Country string` by their underlying kind
+func (d decoder) unmarshalKind(v reflect.Value, data string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(v.Type().Elem())
+		if err := d.unmarshalText(ptr.Interface(), data); err != nil {
+			return err
 		}
-		if isEmptyArray(data) {
-			*v = []string{}
-			return
+		v.Set(ptr)
+	case reflect.String:
+		v.SetString(data)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(data)
+		if err != nil {
+			return err
 		}
-
-		items := splitStringToItemsArray(data)
-		res := make([]string, len(items))
-		for i := 0; i < len(items); i++ {
-			var s string
-			unmarshal(&s, items[i])
-			if len(s) > 1 {
-				res[i] = string(s) //string(s[1: len(s)-1])
-			} else {
-				res[i] = ""
-			}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(data, 10, v.Type().Bits())
+		if err != nil {
+			return err
 		}
-
-		*v = res
-	case *[]VisitParamsString:
-		if !isArray(data) {
-			return fmt.Errorf("Column data is not of type Array")
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(data, 10, v.Type().Bits())
+		if err != nil {
+			return err
 		}
-
-		if isEmptyArray(data) {
-			*v = []VisitParamsString{}
-			return
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(data, v.Type().Bits())
+		if err != nil {
+			return err
 		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("Type %s is not supported for unmarshaling", v.Type())
+	}
+	return nil
+}
 
-		items := splitStringToItemsArray(data)
-
-		res := make([]VisitParamsString, 0)
-
-		for _, item := range items {
-			buffer := VisitParamsString{}
-
-			err := json.Unmarshal([]byte(unescape(item)), &buffer)
+// decodeLiteral puts parsed literal into v, composite values are decoded element by element.
+// NULL is decoded as nil for pointers, interfaces, slices and maps, other types cannot hold it
+func (d decoder) decodeLiteral(v reflect.Value, l literal) error {
+	ptr := v.Addr().Interface()
+	if l.isNull() {
+		switch x := ptr.(type) {
+		case ClickHouseUnmarshaler:
+			return x.UnmarshalClickHouse(`\N`)
+		case sql.Scanner:
+			return x.Scan(nil)
+		}
+		return setNull(v)
+	}
 
+	if !isTextValue(ptr) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			elem := reflect.New(v.Type().Elem())
+			if err := d.decodeLiteral(elem.Elem(), l); err != nil {
+				return err
+			}
+			v.Set(elem)
+			return nil
+		case reflect.Interface:
+			if v.NumMethod() > 0 {
+				break
+			}
+			res, err := literalInterface(l)
 			if err != nil {
-				return fmt.Errorf(err.Error())
+				return err
 			}
-
-			res = append(res, buffer)
+			if res != nil {
+				v.Set(reflect.ValueOf(res))
+			}
+			return nil
+		case reflect.Slice:
+			if l.kind != literalArray && l.kind != literalTuple {
+				break
+			}
+			res := reflect.MakeSlice(v.Type(), len(l.items), len(l.items))
+			for i, item := range l.items {
+				if err := d.decodeLiteral(res.Index(i), item); err != nil {
+					return fmt.Errorf("element %d: %v", i, err)
+				}
+			}
+			v.Set(res)
+			return nil
+		case reflect.Array:
+			if l.kind != literalArray && l.kind != literalTuple {
+				break
+			}
+			if len(l.items) != v.Len() {
+				return fmt.Errorf("Column data is not of type %s: got %d elements", v.Type(), len(l.items))
+			}
+			for i, item := range l.items {
+				if err := d.decodeLiteral(v.Index(i), item); err != nil {
+					return fmt.Errorf("element %d: %v", i, err)
+				}
+			}
+			return nil
+		case reflect.Map:
+			if l.kind != literalMap {
+				break
+			}
+			res := reflect.MakeMapWithSize(v.Type(), len(l.items)/2)
+			for i := 0; i < len(l.items); i += 2 {
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := d.decodeLiteral(key, l.items[i]); err != nil {
+					return fmt.Errorf("key %d: %v", i/2, err)
+				}
+				val := reflect.New(v.Type().Elem()).Elem()
+				if err := d.decodeLiteral(val, l.items[i+1]); err != nil {
+					return fmt.Errorf("value of %q: %v", l.items[i].text, err)
+				}
+				res.SetMapIndex(key, val)
+			}
+			v.Set(res)
+			return nil
+		case reflect.Struct:
+			if l.kind != literalTuple {
+				break
+			}
+			fields := structFields(v.Type())
+			if len(fields) != len(l.items) {
+				return fmt.Errorf("Column data is not of type %s: got %d tuple elements, expected %d", v.Type(), len(l.items), len(fields))
+			}
+			for i, f := range fields {
+				if err := d.decodeLiteral(v.Field(f), l.items[i]); err != nil {
+					return fmt.Errorf("field %s: %v", v.Type().Field(f).Name, err)
+				}
+			}
+			return nil
 		}
+	}
 
-		*v = res
+	if l.kind != literalScalar && l.kind != literalString {
+		return fmt.Errorf("Type %s is not supported for %s values", v.Type(), l.kind)
+	}
+	return d.unmarshalText(ptr, l.text)
+}
+
+// setNull puts NULL into v, only types with nil value can hold it
+func setNull(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	return fmt.Errorf("Cannot unmarshal NULL into %s, use pointer or sql.Null* type", v.Type())
+}
 
-	case *Array:
-		if !isArray(data) {
-			//noinspection GoPlaceholderCount
-			return fmt.Errorf("Column data is not of type Array")
+// literalInterface converts literal into natural go value: int, float64, string, Array, Tuple,
+// map[string]interface{} or nil. Map keys are converted to strings.
+func literalInterface(l literal) (interface{}, error) {
+	switch l.kind {
+	case literalString:
+		return l.text, nil
+	case literalScalar:
+		if l.isNull() {
+			return nil, nil
 		}
-		if isEmptyArray(data) {
-			*v = Array{}
-			return
+		if i, err := strconv.Atoi(l.text); err == nil {
+			return i, nil
 		}
-
-		items := splitStringToItems(data)
-		res := make(Array, len(items))
-
-		var intval int
-		err = unmarshal(&intval, items[0])
-		if err == nil {
-			for i := 0; i < len(items); i++ {
-				unmarshal(&intval, items[i])
-				res[i] = intval
-			}
-
-			*v = res
-			return
+		if f, err := strconv.ParseFloat(l.text, 64); err == nil {
+			return f, nil
 		}
-
-		var floatval float64
-		err = unmarshal(&floatval, items[0])
-		if err == nil {
-			for i := 0; i < len(items); i++ {
-				unmarshal(&floatval, items[i])
-				res[i] = floatval
+		return l.text, nil
+	case literalArray, literalTuple:
+		res := make([]interface{}, len(l.items))
+		for i, item := range l.items {
+			val, err := literalInterface(item)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i, err)
 			}
-
-			*v = res
-			return
+			res[i] = val
 		}
-
-		var stringval string
-		err = unmarshal(&stringval, items[0])
-		if err == nil {
-			for i := 0; i < len(items); i++ {
-				unmarshal(&stringval, items[i])
-				res[i] = string(stringval[1 : len(stringval)-1])
+		if l.kind == literalTuple {
+			return Tuple(res), nil
+		}
+		return Array(res), nil
+	case literalMap:
+		res := make(map[string]interface{}, len(l.items)/2)
+		for i := 0; i < len(l.items); i += 2 {
+			if l.items[i].kind != literalString && l.items[i].kind != literalScalar {
+				return nil, fmt.Errorf("key %d: %s keys are not supported", i/2, l.items[i].kind)
 			}
-
-			*v = res
-			return
+			val, err := literalInterface(l.items[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("value of %q: %v", l.items[i].text, err)
+			}
+			res[l.items[i].text] = val
 		}
-	default:
-		return fmt.Errorf("Type %T is not supported for unmarshaling", v)
+		return res, nil
 	}
+	return nil, fmt.Errorf("Type interface{} is not supported for %s values", l.kind)
+}
 
-	return err
+var (
+	clickHouseMarshalerType = reflect.TypeOf((*ClickHouseMarshaler)(nil)).Elem()
+	valuerType              = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	textMarshalerType       = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// hasMarshalMethods reports if values of t render themselves
+func hasMarshalMethods(t reflect.Type) bool {
+	return t.Implements(clickHouseMarshalerType) || t.Implements(valuerType) || t.Implements(textMarshalerType)
 }
 
-func marshal(value interface{}) string {
-	if reflect.TypeOf(value).Kind() == reflect.Slice {
-		var res []string
-		v := reflect.ValueOf(value)
-		for i := 0; i < v.Len(); i++ {
-			res = append(res, marshal(v.Index(i).Interface()))
+// derefValue returns value behind pointer like database/sql does before calling driver.Valuer, so methods
+// with value receiver are not called on nil pointer. Nil pointer is nil, pointer is kept when only it has methods
+func derefValue(value interface{}) interface{} {
+	for {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Ptr {
+			return value
 		}
-		return "[" + strings.Join(res, ",") + "]"
-	}
-	if t := reflect.TypeOf(value); t.Kind() == reflect.Struct && strings.HasSuffix(t.String(), "Func") {
-		return fmt.Sprintf("%s(%v)", value.(Func).Name, marshal(value.(Func).Args))
+		if rv.IsNil() {
+			return nil
+		}
+		if hasMarshalMethods(rv.Type()) && !hasMarshalMethods(rv.Type().Elem()) {
+			return value
+		}
+		value = rv.Elem().Interface()
 	}
+}
+
+// marshal renders value as SQL literal, unsupported types return error
+func marshal(value interface{}) (string, error) {
+	value = derefValue(value)
 	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case ClickHouseMarshaler:
+		return v.MarshalClickHouse()
 	case string:
-		return fmt.Sprintf("'%s'", escape(v))
+		return quoteString(v), nil
 	case int, int8, int16, int32, int64,
 		uint, uint8, uint16, uint32, uint64,
 		float32, float64:
-		return fmt.Sprintf("%v", v)
+		return fmt.Sprintf("%v", v), nil
+	case *big.Int:
+		if v == nil {
+			return "NULL", nil
+		}
+		return v.String(), nil
+	case Decimal:
+		if err := v.checkScale(); err != nil {
+			return "", err
+		}
+		return v.String(), nil
 	//https://clickhouse.yandex/reference_en.html#Boolean values
 	case bool:
-		if value.(bool) {
-			return "1"
+		if v {
+			return "1", nil
 		}
-		return "0"
-	//Convert time to Date type https://clickhouse.yandex/reference_en.html#Date
+		return "0", nil
 	case time.Time:
-		return value.(time.Time).Format("2006-01-02")
+		return formatDateTime(v), nil
+	case DateTime:
+		return formatDateTime(time.Time(v)), nil
+	case DateTime64:
+		return formatDateTime64(v)
+	case Date:
+		return formatDate(time.Time(v)), nil
+	case Date32:
+		return formatDate(time.Time(v)), nil
+	case UUID:
+		return quoteString(v.String()), nil
+	// IPv4 and IPv6 columns are parsed from string literals
+	case net.IP:
+		if v == nil {
+			return "NULL", nil
+		}
+		return quoteString(v.String()), nil
+	case netip.Addr:
+		return quoteString(v.Unmap().String()), nil
+	case Func:
+		args, err := marshal(v.Args)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s(%v)", v.Name, args), nil
+	case Tuple:
+		return marshalTuple(v)
+	case driver.Valuer:
+		val, err := v.Value()
+		if err != nil {
+			return "", err
+		}
+		if b, ok := val.([]byte); ok {
+			return quoteString(string(b)), nil
+		}
+		return marshal(val)
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return quoteString(string(text)), nil
 	}
 
-	return "''"
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "NULL", nil
+		}
+		return marshal(v.Elem().Interface())
+	case reflect.String:
+		return quoteString(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", nil
+		}
+		return "0", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice, reflect.Array:
+		res := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := marshal(v.Index(i).Interface())
+			if err != nil {
+				return "", fmt.Errorf("element %d: %v", i, err)
+			}
+			res[i] = item
+		}
+		return "[" + strings.Join(res, ",") + "]", nil
+	case reflect.Map:
+		// map() function works both in VALUES and in expressions, unlike {'k':v} literal
+		res := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := marshal(iter.Key().Interface())
+			if err != nil {
+				return "", fmt.Errorf("key %v: %v", iter.Key(), err)
+			}
+			val, err := marshal(iter.Value().Interface())
+			if err != nil {
+				return "", fmt.Errorf("value of %v: %v", iter.Key(), err)
+			}
+			res = append(res, key+", "+val)
+		}
+		sort.Strings(res)
+		return "map(" + strings.Join(res, ", ") + ")", nil
+	case reflect.Struct:
+		if _, ok := tupleTypes.Load(v.Type()); !ok {
+			break
+		}
+		fields := structFields(v.Type())
+		items := make(Tuple, len(fields))
+		for i, f := range fields {
+			items[i] = v.Field(f).Interface()
+		}
+		return marshalTuple(items)
+	}
+
+	return "", fmt.Errorf("Type %T is not supported for marshaling", value)
+}
+
+// tupleTypes are struct types registered by RegisterTuple
+var tupleTypes sync.Map
+
+// RegisterTuple allows marshaling of struct type to tuple literal, exported fields are tuple elements in order
+// of declaration. Other structs are not marshaled, so unexpected types are not sent silently:
+//
+//	clickhouse.RegisterTuple(Point{})
+func RegisterTuple(example interface{}) {
+	t := reflect.TypeOf(example)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("clickhouse: RegisterTuple of non-struct type %T", example))
+	}
+	tupleTypes.Store(t, true)
+}
+
+func marshalTuple(items Tuple) (string, error) {
+	res := make([]string, len(items))
+	for i, item := range items {
+		val, err := marshal(item)
+		if err != nil {
+			return "", fmt.Errorf("element %d: %v", i, err)
+		}
+		res[i] = val
+	}
+	return "tuple(" + strings.Join(res, ", ") + ")", nil
+}
+
+// structFields returns indexes of exported fields, they are mapped to tuple elements by order
+func structFields(t reflect.Type) []int {
+	var res []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+func parseIP(data string) (net.IP, error) {
+	ip := net.ParseIP(data)
+	if ip == nil {
+		return nil, fmt.Errorf("Cannot parse %q as IP address", data)
+	}
+	return ip, nil
 }
@@ -1,6 +1,13 @@
 package clickhouse
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
 	"testing"
 	"time"
 
@@ -13,6 +20,12 @@ func BenchmarkMarshalString(b *testing.B) {
 	}
 }
 
+func mustMarshal(t *testing.T, value interface{}) string {
+	res, err := marshal(value)
+	assert.NoError(t, err)
+	return res
+}
+
 func TestUnmarshal(t *testing.T) {
 	var (
 		err             error
@@ -122,30 +135,412 @@ func TestUnmarshal(t *testing.T) {
 }
 
 func TestMarshal(t *testing.T) {
-	assert.Equal(t, "10", marshal(10))
-	assert.Equal(t, "10", marshal(int8(10)))
-	assert.Equal(t, "10", marshal(int16(10)))
-	assert.Equal(t, "10", marshal(int32(10)))
-	assert.Equal(t, "10", marshal(int64(10)))
-
-	assert.Equal(t, "1", marshal(true))
-	assert.Equal(t, "0", marshal(false))
-
-	assert.Equal(t, "3.141592", marshal(float32(3.141592)))
-	assert.Equal(t, "3.1415926535", marshal(float64(3.1415926535)))
-
-	assert.Equal(t, "'10'", marshal("10"))
-	assert.Equal(t, "'String1\\''", marshal("String1'"))
-	assert.Equal(t, "'String\r'", marshal("String\r"))
-	assert.Equal(t, "'String\r'", marshal("String\r"))
-	assert.Equal(t, `'String\\'`, marshal(`String\`))
-	assert.Equal(t, "[10,20,30]", marshal(Array{10, 20, 30}))
-	assert.Equal(t, "['k10','20','30val']", marshal(Array{"k10", "20", "30val"}))
-	assert.Equal(t, "['k10','20','30val']", marshal([]string{"k10", "20", "30val"}))
-	assert.Equal(t, "['k10','20','30val\\\\']", marshal([]string{"k10", "20", "30val\\"}))
-	assert.Equal(t, "[10,20,30]", marshal([]int{10, 20, 30}))
-	assert.Equal(t, "IPv4StringToNum('192.0.2.128')", marshal(Func{"IPv4StringToNum", "192.0.2.128"}))
-	assert.Equal(t, "IPv4NumToString(3221225985)", marshal(Func{"IPv4NumToString", 3221225985}))
-	assert.Equal(t, "''", marshal(t))
-	assert.Equal(t, "2017-04-10", marshal(time.Date(2017, 04, 10, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "10", mustMarshal(t, 10))
+	assert.Equal(t, "10", mustMarshal(t, int8(10)))
+	assert.Equal(t, "10", mustMarshal(t, int16(10)))
+	assert.Equal(t, "10", mustMarshal(t, int32(10)))
+	assert.Equal(t, "10", mustMarshal(t, int64(10)))
+
+	assert.Equal(t, "1", mustMarshal(t, true))
+	assert.Equal(t, "0", mustMarshal(t, false))
+
+	assert.Equal(t, "3.141592", mustMarshal(t, float32(3.141592)))
+	assert.Equal(t, "3.1415926535", mustMarshal(t, float64(3.1415926535)))
+
+	assert.Equal(t, "'10'", mustMarshal(t, "10"))
+	assert.Equal(t, "'String1\\''", mustMarshal(t, "String1'"))
+	assert.Equal(t, "'String\r'", mustMarshal(t, "String\r"))
+	assert.Equal(t, "'String\r'", mustMarshal(t, "String\r"))
+	assert.Equal(t, `'String\\'`, mustMarshal(t, `String\`))
+	assert.Equal(t, "[10,20,30]", mustMarshal(t, Array{10, 20, 30}))
+	assert.Equal(t, "['k10','20','30val']", mustMarshal(t, Array{"k10", "20", "30val"}))
+	assert.Equal(t, "['k10','20','30val']", mustMarshal(t, []string{"k10", "20", "30val"}))
+	assert.Equal(t, "['k10','20','30val\\\\']", mustMarshal(t, []string{"k10", "20", "30val\\"}))
+	assert.Equal(t, "[10,20,30]", mustMarshal(t, []int{10, 20, 30}))
+	assert.Equal(t, "IPv4StringToNum('192.0.2.128')", mustMarshal(t, Func{"IPv4StringToNum", "192.0.2.128"}))
+	assert.Equal(t, "IPv4NumToString(3221225985)", mustMarshal(t, Func{"IPv4NumToString", 3221225985}))
+	_, err := marshal(t)
+	assert.Error(t, err)
+	assert.Equal(t, "toDateTime('2017-04-10 00:00:00', 'UTC')", mustMarshal(t, time.Date(2017, 04, 10, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "'2017-04-10'", mustMarshal(t, Date(time.Date(2017, 04, 10, 0, 0, 0, 0, time.UTC))))
+}
+
+func TestEscapeTSV(t *testing.T) {
+	assert.Equal(t, "plain", EscapeTSV("plain"))
+	assert.Equal(t, `a\tb\nc\rd\0e\bf\fg\\h\'i`, EscapeTSV("a\tb\nc\rd\x00e\bf\fg\\h'i"))
+
+	for _, s := range []string{"", "a\tb", "line1\nline2", "\\t", "\x00\xff\xfe", "тест\r\n", `\'\\`} {
+		assert.Equal(t, s, unescapeTSV(EscapeTSV(s)))
+	}
+
+	assert.Equal(t, "\x01\xffz", unescapeTSV(`\x01\xFF\z`))
+	assert.Equal(t, `\`, unescapeTSV(`\`))
+}
+
+func TestMarshalStringEscaping(t *testing.T) {
+	assert.Equal(t, `'a\0b'`, mustMarshal(t, "a\x00b"))
+	assert.Equal(t, "'a\tb\nc'", mustMarshal(t, "a\tb\nc"))
+	assert.Equal(t, "'\xff\xfe\\\\\\''", mustMarshal(t, "\xff\xfe\\'"))
+}
+
+func TestUnmarshalArrays(t *testing.T) {
+	var (
+		strs       []string
+		nested     [][]string
+		nestedInts [][]int64
+		times      []time.Time
+		ptrs       []*int32
+		tuples     []Array
+		arr        Array
+	)
+
+	assert.NoError(t, unmarshal(&strs, `['a,b','c\'d','','x\\','y\tz']`))
+	assert.Equal(t, []string{"a,b", "c'd", "", `x\`, "y\tz"}, strs)
+
+	assert.NoError(t, unmarshal(&nested, `[['a','b'],[],['c']]`))
+	assert.Equal(t, [][]string{{"a", "b"}, {}, {"c"}}, nested)
+
+	assert.NoError(t, unmarshal(&nestedInts, `[[1,2],[3]]`))
+	assert.Equal(t, [][]int64{{1, 2}, {3}}, nestedInts)
+
+	assert.NoError(t, unmarshal(&times, `['2016-10-07 19:21:17']`))
+	assert.Equal(t, []time.Time{time.Date(2016, 10, 7, 19, 21, 17, 0, time.UTC)}, times)
+
+	assert.NoError(t, unmarshal(&ptrs, `[1,NULL,3]`))
+	if assert.Len(t, ptrs, 3) {
+		assert.Equal(t, int32(1), *ptrs[0])
+		assert.Nil(t, ptrs[1])
+		assert.Equal(t, int32(3), *ptrs[2])
+	}
+
+	assert.NoError(t, unmarshal(&tuples, `[('a',1),('b',2.5)]`))
+	assert.Equal(t, []Array{{"a", 1}, {"b", 2.5}}, tuples)
+
+	assert.NoError(t, unmarshal(&arr, `[1,'two',[3]]`))
+	assert.Equal(t, Array{1, "two", Array{3}}, arr)
+
+	var vps []VisitParamsString
+	assert.NoError(t, unmarshal(&vps, `['{"a":"b\'c"}']`))
+	assert.Equal(t, []VisitParamsString{{"a": "b'c"}}, vps)
+}
+
+func TestUnmarshalArrayErrors(t *testing.T) {
+	var (
+		ints   []int8
+		nested [][]int
+	)
+
+	err := unmarshal(&ints, "[1,300]")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "element 1")
+	}
+
+	err = unmarshal(&nested, "[[1],['x']]")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "element 1: element 0")
+	}
+
+	assert.Error(t, unmarshal(&nested, "[1,2]"))
+	assert.Error(t, unmarshal(&ints, "['a,b'"))
+}
+
+func TestUnmarshalNumbers(t *testing.T) {
+	var (
+		u    uint
+		u8   uint8
+		u16  uint16
+		u32  uint32
+		u64  uint64
+		i8   int8
+		bi   big.Int
+		dec  Decimal
+		us   []uint64
+		ptrs []*uint8
+	)
+
+	assert.NoError(t, unmarshal(&u, "10"))
+	assert.Equal(t, uint(10), u)
+	assert.NoError(t, unmarshal(&u8, "255"))
+	assert.Equal(t, uint8(255), u8)
+	assert.NoError(t, unmarshal(&u16, "65535"))
+	assert.Equal(t, uint16(65535), u16)
+	assert.NoError(t, unmarshal(&u32, "4294967295"))
+	assert.Equal(t, uint32(4294967295), u32)
+	assert.NoError(t, unmarshal(&u64, "18446744073709551615"))
+	assert.Equal(t, uint64(18446744073709551615), u64)
+
+	assert.NoError(t, unmarshal(&bi, "-170141183460469231731687303715884105728"))
+	assert.Equal(t, "-170141183460469231731687303715884105728", bi.String())
+	assert.Error(t, unmarshal(&bi, "1.5"))
+	assert.Equal(t, "-170141183460469231731687303715884105728", bi.String())
+
+	assert.NoError(t, unmarshal(&dec, "-12.3400"))
+	assert.Equal(t, Decimal{Value: big.NewInt(-123400), Scale: 4}, dec)
+
+	assert.NoError(t, unmarshal(&us, "[1,18446744073709551615]"))
+	assert.Equal(t, []uint64{1, 18446744073709551615}, us)
+
+	assert.NoError(t, unmarshal(&ptrs, "[NULL,7]"))
+	assert.Nil(t, ptrs[0])
+	assert.Equal(t, uint8(7), *ptrs[1])
+
+	// overflow must not be truncated silently
+	u8 = 1
+	assert.Error(t, unmarshal(&u8, "256"))
+	assert.Equal(t, uint8(1), u8)
+	assert.Error(t, unmarshal(&u16, "-1"))
+	assert.Error(t, unmarshal(&u64, "18446744073709551616"))
+	i8 = 1
+	assert.Error(t, unmarshal(&i8, "-129"))
+	assert.Equal(t, int8(1), i8)
+}
+
+func TestMarshalNumbers(t *testing.T) {
+	assert.Equal(t, "18446744073709551615", mustMarshal(t, uint64(18446744073709551615)))
+	assert.Equal(t, "255", mustMarshal(t, uint8(255)))
+
+	bi, _ := new(big.Int).SetString("-170141183460469231731687303715884105728", 10)
+	assert.Equal(t, "-170141183460469231731687303715884105728", mustMarshal(t, bi))
+	assert.Equal(t, "NULL", mustMarshal(t, (*big.Int)(nil)))
+
+	assert.Equal(t, "123.4500", mustMarshal(t, NewDecimal(1234500, 4)))
+	assert.Equal(t, "[1.5,-0.01]", mustMarshal(t, []Decimal{NewDecimal(15, 1), NewDecimal(-1, 2)}))
+}
+
+func TestMarshalNetwork(t *testing.T) {
+	assert.Equal(t, "'192.0.2.128'", mustMarshal(t, net.ParseIP("192.0.2.128")))
+	assert.Equal(t, "'2001:db8::1'", mustMarshal(t, net.ParseIP("2001:db8::1")))
+	assert.Equal(t, "NULL", mustMarshal(t, net.IP(nil)))
+	assert.Equal(t, "'192.0.2.128'", mustMarshal(t, netip.MustParseAddr("::ffff:192.0.2.128")))
+	assert.Equal(t, "'2001:db8::1'", mustMarshal(t, netip.MustParseAddr("2001:db8::1")))
+	assert.Equal(t, "['192.0.2.1','::1']", mustMarshal(t, []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("::1")}))
+	assert.Equal(t, "'417ddc5d-e556-4d27-95dd-a34d84e46a50'",
+		mustMarshal(t, UUID{0x41, 0x7d, 0xdc, 0x5d, 0xe5, 0x56, 0x4d, 0x27, 0x95, 0xdd, 0xa3, 0x4d, 0x84, 0xe4, 0x6a, 0x50}))
+}
+
+func TestUnmarshalNetwork(t *testing.T) {
+	var (
+		ip    net.IP
+		addr  netip.Addr
+		u     UUID
+		ips   []net.IP
+		uuids []UUID
+	)
+
+	assert.NoError(t, unmarshal(&ip, "192.0.2.128"))
+	assert.True(t, net.ParseIP("192.0.2.128").Equal(ip))
+	assert.Error(t, unmarshal(&ip, "192.0.2"))
+
+	assert.NoError(t, unmarshal(&addr, "2001:db8::1"))
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), addr)
+
+	assert.NoError(t, unmarshal(&u, "417ddc5d-e556-4d27-95dd-a34d84e46a50"))
+	assert.Equal(t, "417ddc5d-e556-4d27-95dd-a34d84e46a50", u.String())
+
+	assert.NoError(t, unmarshal(&ips, "['192.0.2.1','::1']"))
+	assert.Equal(t, []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("::1")}, ips)
+
+	assert.NoError(t, unmarshal(&uuids, "['00000000-0000-0000-0000-000000000001']"))
+	assert.Equal(t, []UUID{{15: 1}}, uuids)
+}
+
+type tupleTest struct {
+	Name  string
+	Count uint8
+	skip  int
+}
+
+func TestMarshalMapTuple(t *testing.T) {
+	// structs are tuples only when registered
+	_, err := marshal(struct{ A int }{1})
+	assert.Error(t, err)
+	RegisterTuple(tupleTest{})
+	assert.Panics(t, func() { RegisterTuple(1) })
+
+	assert.Equal(t, "map('a', 1, 'b', 2)", mustMarshal(t, map[string]int{"b": 2, "a": 1}))
+	assert.Equal(t, "map()", mustMarshal(t, map[string]int{}))
+	assert.Equal(t, "map(1, ['x','y'])", mustMarshal(t, map[uint8][]string{1: {"x", "y"}}))
+	assert.Equal(t, "tuple('a', 1)", mustMarshal(t, Tuple{"a", 1}))
+	assert.Equal(t, `tuple('a\'')`, mustMarshal(t, Tuple{"a'"}))
+	assert.Equal(t, "tuple('n', 3)", mustMarshal(t, tupleTest{Name: "n", Count: 3, skip: 1}))
+	assert.Equal(t, "[tuple('a', 1),tuple('b', 2)]", mustMarshal(t, []tupleTest{{"a", 1, 0}, {"b", 2, 0}}))
+	assert.Equal(t, "map('k', tuple('a', 1))", mustMarshal(t, map[string]Tuple{"k": {"a", 1}}))
+}
+
+func TestUnmarshalMapTuple(t *testing.T) {
+	var (
+		m      map[string]uint64
+		nested map[string][]int
+		tuple  Tuple
+		st     tupleTest
+		sts    []tupleTest
+		arr    Array
+		iface  interface{}
+	)
+
+	assert.NoError(t, unmarshal(&m, "{'a':1,'b\\'c':2}"))
+	assert.Equal(t, map[string]uint64{"a": 1, "b'c": 2}, m)
+
+	assert.NoError(t, unmarshal(&m, "{}"))
+	assert.Equal(t, map[string]uint64{}, m)
+
+	assert.NoError(t, unmarshal(&nested, "{'x':[1,2],'y':[]}"))
+	assert.Equal(t, map[string][]int{"x": {1, 2}, "y": {}}, nested)
+
+	assert.NoError(t, unmarshal(&tuple, "('a',1,[2])"))
+	assert.Equal(t, Tuple{"a", 1, Array{2}}, tuple)
+
+	assert.NoError(t, unmarshal(&st, "('name',7)"))
+	assert.Equal(t, tupleTest{Name: "name", Count: 7}, st)
+
+	assert.NoError(t, unmarshal(&sts, "[('a',1),('b',2)]"))
+	assert.Equal(t, []tupleTest{{"a", 1, 0}, {"b", 2, 0}}, sts)
+
+	assert.NoError(t, unmarshal(&arr, "[('a',1),{'k':'v'}]"))
+	assert.Equal(t, Array{Tuple{"a", 1}, map[string]interface{}{"k": "v"}}, arr)
+
+	d := decoder{}
+	assert.NoError(t, d.decodeLiteral(reflect.ValueOf(&iface).Elem(), literal{kind: literalMap, items: []literal{
+		{kind: literalScalar, text: "1"}, {kind: literalScalar, text: "NULL"},
+	}}))
+	assert.Equal(t, map[string]interface{}{"1": nil}, iface)
+
+	err := unmarshal(&sts, "[('a',1),('b',300)]")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "element 1: field Count")
+	}
+	assert.Error(t, unmarshal(&st, "('a',1,2)"))
+	assert.Error(t, unmarshal(&st, "['a',1]"))
+	assert.Error(t, unmarshal(&m, "{'a':'x'}"))
+	assert.Error(t, unmarshal(&m, "[1]"))
+}
+
+type money int64
+
+func (m money) MarshalClickHouse() (string, error) {
+	return fmt.Sprintf("toDecimal64('%d.%02d', 2)", m/100, m%100), nil
+}
+
+func (m *money) UnmarshalClickHouse(data string) error {
+	d, err := ParseDecimal(data)
+	if err != nil {
+		return err
+	}
+	d, err = d.Rescale(2)
+	if err != nil {
+		return err
+	}
+	*m = money(d.Value.Int64())
+	return nil
+}
+
+type country string
+
+type level struct {
+	name string
+}
+
+func (l level) MarshalText() ([]byte, error) {
+	if l.name == "" {
+		return nil, errors.New("empty level")
+	}
+	return []byte(l.name), nil
+}
+
+func (l *level) UnmarshalText(data []byte) error {
+	l.name = string(data)
+	return nil
+}
+
+func TestMarshalInterfaces(t *testing.T) {
+	assert.Equal(t, "toDecimal64('12.05', 2)", mustMarshal(t, money(1205)))
+	assert.Equal(t, "'RU'", mustMarshal(t, country("RU")))
+	assert.Equal(t, "'debug'", mustMarshal(t, level{"debug"}))
+	assert.Equal(t, "'str'", mustMarshal(t, sql.NullString{String: "str", Valid: true}))
+	assert.Equal(t, "NULL", mustMarshal(t, sql.NullString{}))
+	assert.Equal(t, "5", mustMarshal(t, sql.NullInt64{Int64: 5, Valid: true}))
+	assert.Equal(t, "NULL", mustMarshal(t, nil))
+	assert.Equal(t, "NULL", mustMarshal(t, (*int)(nil)))
+	five := 5
+	assert.Equal(t, "[5,NULL]", mustMarshal(t, []*int{&five, nil}))
+
+	// methods with value receiver are not called on nil pointers, other pointers are dereferenced
+	assert.Equal(t, "NULL", mustMarshal(t, (*time.Time)(nil)))
+	assert.Equal(t, "NULL", mustMarshal(t, (*sql.NullString)(nil)))
+	assert.Equal(t, "NULL", mustMarshal(t, (*net.IP)(nil)))
+	assert.Equal(t, "NULL", mustMarshal(t, (*UUID)(nil)))
+	assert.Equal(t, "NULL", mustMarshal(t, (*big.Int)(nil)))
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, mustMarshal(t, tm), mustMarshal(t, &tm))
+	ns := sql.NullString{String: "str", Valid: true}
+	assert.Equal(t, "'str'", mustMarshal(t, &ns))
+	ip := net.ParseIP("10.0.0.1")
+	assert.Equal(t, "'10.0.0.1'", mustMarshal(t, &ip))
+	assert.Equal(t, "42", mustMarshal(t, big.NewInt(42)))
+
+	_, err := marshal(level{})
+	assert.Error(t, err)
+	_, err = marshal(make(chan int))
+	assert.Error(t, err)
+	_, err = marshal([]interface{}{1, make(chan int)})
+	assert.Error(t, err)
+	_, err = marshal(map[string]interface{}{"k": func() {}})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalInterfaces(t *testing.T) {
+	var (
+		m   money
+		c   country
+		l   level
+		ns  sql.NullString
+		ni  sql.NullInt64
+		ms  []money
+		nss []sql.NullString
+		b   []byte
+		ok  bool
+	)
+
+	assert.NoError(t, unmarshal(&m, "12.5"))
+	assert.Equal(t, money(1250), m)
+	assert.Error(t, unmarshal(&m, "12.555"))
+
+	assert.NoError(t, unmarshal(&c, "R\\tU"))
+	assert.Equal(t, country("R\tU"), c)
+
+	assert.NoError(t, unmarshal(&l, "in\\nfo"))
+	assert.Equal(t, level{"in\nfo"}, l)
+
+	assert.NoError(t, unmarshal(&ns, "a\\'b"))
+	assert.Equal(t, sql.NullString{String: "a'b", Valid: true}, ns)
+	assert.NoError(t, unmarshal(&ns, `\N`))
+	assert.Equal(t, sql.NullString{}, ns)
+
+	assert.NoError(t, unmarshal(&ni, "42"))
+	assert.Equal(t, sql.NullInt64{Int64: 42, Valid: true}, ni)
+
+	assert.NoError(t, unmarshal(&ms, "[1.5,2]"))
+	assert.Equal(t, []money{150, 200}, ms)
+
+	assert.NoError(t, unmarshal(&nss, "['a\\'',NULL]"))
+	assert.Equal(t, []sql.NullString{{String: "a'", Valid: true}, {}}, nss)
+
+	// NULL needs destination which can hold it
+	var (
+		n    int
+		ints []int
+		np   *int
+	)
+	assert.Error(t, unmarshal(&n, `\N`))
+	assert.Error(t, unmarshal(&ints, "[1,NULL]"))
+	assert.NoError(t, unmarshal(&np, `\N`))
+	assert.Nil(t, np)
+
+	assert.NoError(t, unmarshal(&b, "\\x00\\xff"))
+	assert.Equal(t, []byte{0, 0xff}, b)
+
+	assert.NoError(t, unmarshal(&ok, "true"))
+	assert.True(t, ok)
+	assert.NoError(t, unmarshal(&ok, "0"))
+	assert.False(t, ok)
 }
@@ -0,0 +1,132 @@
+package clickhouse
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsCollector receives measurements of requests and cluster checks, see package chprom for Prometheus collector.
+// Methods are called concurrently
+type MetricsCollector interface {
+	// RequestStarted is called before request is sent, kind is one of Call kinds,
+	// statement is first keyword of query like SELECT or INSERT, see StatementKind
+	RequestStarted(host, kind, statement string)
+	// RequestFinished is called when response is read or stream is closed with sizes of request and response bodies,
+	// err is *DbError for server exceptions
+	RequestFinished(host, kind, statement string, elapsed time.Duration, sent, received int64, err error)
+	// NodeChecked is called for every connection pinged by Cluster.Check with last and average ping time
+	NodeChecked(host string, last, avg time.Duration, err error)
+	// ClusterChecked is called after Cluster.Check with amount of active and all connections
+	ClusterChecked(active, total int)
+}
+
+// WithMetrics returns copy of transport which reports requests to m
+func (t HttpTransport) WithMetrics(m MetricsCollector) HttpTransport {
+	t.metrics = m
+	return t
+}
+
+// SetMetrics sets collector of Check results
+func (c *Cluster) SetMetrics(m MetricsCollector) {
+	c.metrics = m
+}
+
+// StatementKind returns upper case first keyword of statement, e.g. SELECT, INSERT or ALTER.
+// WITH queries are SELECT, OTHER is returned when statement does not start with keyword
+func StatementKind(stmt string) string {
+	for {
+		stmt = strings.TrimLeft(stmt, " \t\r\n(")
+		if strings.HasPrefix(stmt, "--") {
+			if pos := strings.IndexByte(stmt, '\n'); pos >= 0 {
+				stmt = stmt[pos+1:]
+				continue
+			}
+			return "OTHER"
+		}
+		if strings.HasPrefix(stmt, "/*") {
+			if pos := strings.Index(stmt, "*/"); pos >= 0 {
+				stmt = stmt[pos+2:]
+				continue
+			}
+			return "OTHER"
+		}
+		break
+	}
+	end := 0
+	for end < len(stmt) && (stmt[end] >= 'a' && stmt[end] <= 'z' || stmt[end] >= 'A' && stmt[end] <= 'Z') {
+		end++
+	}
+	if end == 0 {
+		return "OTHER"
+	}
+	switch kind := strings.ToUpper(stmt[:end]); kind {
+	case "WITH":
+		return "SELECT"
+	case "DESC":
+		return "DESCRIBE"
+	default:
+		return kind
+	}
+}
+
+// countingReader counts bytes of request body, it is read by http.Transport goroutine
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// count returns amount of read bytes, zero for nil reader
+func (r *countingReader) count() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.n.Load()
+}
+
+// meteredBody reports finished request when response body is closed
+type meteredBody struct {
+	io.ReadCloser
+	metrics   MetricsCollector
+	host      string
+	kind      string
+	statement string
+	start     time.Time
+	sent      *countingReader
+	received  int64
+	err       error
+	once      sync.Once
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received += int64(n)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.metrics.RequestFinished(b.host, b.kind, b.statement, time.Since(b.start), b.sent.count(), b.received, b.err)
+	})
+	return err
+}
+
+// setResponseError reports error of response which was received, e.g. server exception
+func setResponseError(resp *http.Response, err error) {
+	if b, ok := resp.Body.(*meteredBody); ok && b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,123 @@
+package clickhouse
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRequest struct {
+	host, kind, statement string
+	sent, received        int64
+	err                   error
+}
+
+type testMetrics struct {
+	mx       sync.Mutex
+	started  int
+	requests []testRequest
+	pings    map[string]error
+	active   int
+	total    int
+}
+
+func (m *testMetrics) RequestStarted(host, kind, statement string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.started++
+}
+
+func (m *testMetrics) RequestFinished(host, kind, statement string, elapsed time.Duration, sent, received int64, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.requests = append(m.requests, testRequest{host, kind, statement, sent, received, err})
+}
+
+func (m *testMetrics) NodeChecked(host string, last, avg time.Duration, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.pings == nil {
+		m.pings = make(map[string]error)
+	}
+	m.pings[host] = err
+}
+
+func (m *testMetrics) ClusterChecked(active, total int) {
+	m.active, m.total = active, total
+}
+
+func TestHttpTransport_WithMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist")
+			return
+		}
+		io.WriteString(w, "1\n")
+	}))
+	defer server.Close()
+	m := &testMetrics{}
+	conn := NewConn(server.URL, NewHttpTransport().WithMetrics(m))
+
+	assert.NoError(t, NewQuery("SELECT 1").Exec(conn))
+	assert.NoError(t, conn.Ping())
+	assert.Error(t, NewQuery("SELECT * FROM missing").Exec(conn))
+	body, err := NewQuery("SELECT 1").Stream(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(m.requests))
+	io.ReadAll(body)
+	body.Close()
+	body.Close()
+
+	assert.Equal(t, 4, m.started)
+	if assert.Len(t, m.requests, 4) {
+		assert.Equal(t, testRequest{conn.Host, CallExec, "SELECT", 8, 2, nil}, m.requests[0])
+		assert.Equal(t, CallPing, m.requests[1].kind)
+		assert.Equal(t, int64(0), m.requests[1].sent)
+		if assert.Error(t, m.requests[2].err) {
+			assert.Equal(t, 60, m.requests[2].err.(*DbError).Code())
+		}
+		assert.Equal(t, CallStream, m.requests[3].kind)
+		assert.Equal(t, int64(2), m.requests[3].received)
+	}
+
+	m = &testMetrics{}
+	conn = NewConn("http://127.0.0.1:1", NewHttpTransport().WithMetrics(m))
+	assert.Error(t, NewQuery("SELECT 1").Exec(conn))
+	if assert.Len(t, m.requests, 1) {
+		assert.Error(t, m.requests[0].err)
+	}
+}
+
+func TestStatementKind(t *testing.T) {
+	assert.Equal(t, "SELECT", StatementKind("select 1"))
+	assert.Equal(t, "SELECT", StatementKind("  WITH 1 AS x SELECT x"))
+	assert.Equal(t, "SELECT", StatementKind("(SELECT 1) UNION ALL (SELECT 2)"))
+	assert.Equal(t, "INSERT", StatementKind("-- load\n/* clicks */ INSERT INTO t FORMAT CSV"))
+	assert.Equal(t, "DESCRIBE", StatementKind("DESC t"))
+	assert.Equal(t, "ALTER", StatementKind("ALTER TABLE t DELETE WHERE 1"))
+	assert.Equal(t, "OTHER", StatementKind(""))
+	assert.Equal(t, "OTHER", StatementKind("/* unclosed"))
+}
+
+func TestCluster_SetMetrics(t *testing.T) {
+	conn1 := NewConn("host1", badTransport{err: errors.New("Connection timeout")})
+	conn2 := NewConn("host2", getMockTransport("1"))
+	cl := NewCluster(conn1, conn2)
+	m := &testMetrics{}
+	cl.SetMetrics(m)
+	cl.Check()
+
+	assert.Equal(t, 1, m.active)
+	assert.Equal(t, 2, m.total)
+	assert.Error(t, m.pings[conn1.Host])
+	assert.NoError(t, m.pings[conn2.Host])
+}
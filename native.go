@@ -0,0 +1,870 @@
+package clickhouse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const FormatNative = "Native"
+
+// LowCardinality serialization flags, dictionaries are always sent with block as additional keys
+const (
+	lowCardinalitySharedKeys       = 1
+	lowCardinalityAdditionalKeys   = 1 << 9
+	lowCardinalityUpdateDictionary = 1 << 10
+)
+
+// Block is set of column vectors decoded from Native format. Columns are typed slices:
+// UInt64 is []uint64, String is []string, DateTime is []time.Time, Array(Float64) is [][]float64, etc.
+// Nullable columns have zero values in place of NULL, use Nulls to distinguish them
+type Block struct {
+	schema  Schema
+	rows    int
+	columns []interface{}
+	nulls   [][]bool
+}
+
+// Columns returns names and types of block columns
+func (b *Block) Columns() Schema {
+	return b.schema
+}
+
+// Rows returns amount of rows in block
+func (b *Block) Rows() int {
+	return b.rows
+}
+
+// Column returns column vector by name
+func (b *Block) Column(name string) (interface{}, error) {
+	for i, col := range b.schema {
+		if col.Name == name {
+			return b.columns[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Block has no column %s", name)
+}
+
+// Nulls returns NULL flags of Nullable column, it is nil for other columns
+func (b *Block) Nulls(name string) []bool {
+	for i, col := range b.schema {
+		if col.Name == name {
+			return b.nulls[i]
+		}
+	}
+	return nil
+}
+
+func (b *Block) Int8Column(name string) (res []int8, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Int16Column(name string) (res []int16, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Int32Column(name string) (res []int32, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Int64Column(name string) (res []int64, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Uint8Column(name string) (res []uint8, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Uint16Column(name string) (res []uint16, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Uint32Column(name string) (res []uint32, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Uint64Column(name string) (res []uint64, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Float32Column(name string) (res []float32, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) Float64Column(name string) (res []float64, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) BoolColumn(name string) (res []bool, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+// StringColumn returns String, FixedString and Enum columns, Enum values are names
+func (b *Block) StringColumn(name string) (res []string, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+// TimeColumn returns Date, Date32, DateTime and DateTime64 columns
+func (b *Block) TimeColumn(name string) (res []time.Time, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) DecimalColumn(name string) (res []Decimal, err error) {
+	return res, b.typedColumn(name, &res)
+}
+
+func (b *Block) typedColumn(name string, dst interface{}) error {
+	col, err := b.Column(name)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(dst).Elem()
+	if reflect.TypeOf(col) != v.Type() {
+		return fmt.Errorf("Column %s is %T, not %s", name, col, v.Type())
+	}
+	v.Set(reflect.ValueOf(col))
+	return nil
+}
+
+// BlockIter reads Native blocks while response is received:
+//
+//	blocks := clickhouse.NewQuery("SELECT id, price FROM orders").Blocks(conn)
+//	defer blocks.Close()
+//	for blocks.Next() {
+//		ids, err := blocks.Block().Uint64Column("id")
+//	}
+//	err := blocks.Error()
+type BlockIter struct {
+	body  io.ReadCloser
+	r     binaryReader
+	block *Block
+	err   error
+}
+
+// Blocks makes request in Native format, blocks are read on Next calls
+func (q Query) Blocks(conn Connector) *BlockIter {
+	if conn == nil {
+		return &BlockIter{err: errors.New("Connection pointer is nil")}
+	}
+	q.Stmt += " FORMAT " + FormatNative
+
+	body, err := streamQuery(conn, q)
+	if err != nil {
+		return &BlockIter{err: err}
+	}
+	return &BlockIter{
+		body: body,
+		r:    newBinaryReader(body, q.queryLocation(conn)),
+	}
+}
+
+// Next reads next block, empty blocks are skipped
+func (it *BlockIter) Next() bool {
+	for it.err == nil && it.body != nil {
+		if _, err := it.r.r.Peek(1); err != nil {
+			if err != io.EOF {
+				it.err = err
+			}
+			it.Close()
+			return false
+		}
+		block, err := it.r.readBlock()
+		if err != nil {
+			it.err = err
+			it.Close()
+			return false
+		}
+		if block.rows > 0 {
+			it.block = block
+			return true
+		}
+	}
+	return false
+}
+
+// Block returns block read by last Next call
+func (it *BlockIter) Block() *Block {
+	return it.block
+}
+
+func (it *BlockIter) Error() error {
+	return it.err
+}
+
+// Close releases response body, it is closed automatically when all blocks are read
+func (it *BlockIter) Close() error {
+	if it.body == nil {
+		return nil
+	}
+	err := it.body.Close()
+	it.body = nil
+	return err
+}
+
+func (r *binaryReader) readBlock() (*Block, error) {
+	cols, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if cols > math.MaxInt32 || rows > math.MaxInt32 {
+		return nil, fmt.Errorf("Bad Native block size: %d columns, %d rows", cols, rows)
+	}
+
+	b := &Block{
+		schema:  make(Schema, cols),
+		rows:    int(rows),
+		columns: make([]interface{}, cols),
+		nulls:   make([][]bool, cols),
+	}
+	for i := range b.schema {
+		col := &b.schema[i]
+		if col.Name, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if col.Type, err = r.readString(); err != nil {
+			return nil, err
+		}
+		t, err := parseColumnType(col.Type)
+		if err != nil {
+			return nil, err
+		}
+		// empty columns have no data at all
+		if rows > 0 {
+			if err = r.readPrefix(t); err != nil {
+				return nil, readError("column "+col.Name, err)
+			}
+		}
+		values, nulls, err := r.readColumn(t, b.rows)
+		if err != nil {
+			return nil, readError("column "+col.Name, err)
+		}
+		b.columns[i] = values.Interface()
+		b.nulls[i] = nulls
+	}
+	return b, nil
+}
+
+// readPrefix reads column state written before column data
+func (r *binaryReader) readPrefix(t *ColumnType) error {
+	if t.Name == "LowCardinality" {
+		version, err := r.readUint(64)
+		if err != nil {
+			return err
+		}
+		if version != lowCardinalitySharedKeys {
+			return fmt.Errorf("Unsupported LowCardinality serialization version %d", version)
+		}
+		return nil
+	}
+	for _, arg := range t.Args {
+		if err := r.readPrefix(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readColumn reads rows values of column, NULL flags are returned for Nullable columns
+func (r *binaryReader) readColumn(t *ColumnType, rows int) (reflect.Value, []bool, error) {
+	switch t.Name {
+	case "Nullable":
+		flags, err := r.read(rows)
+		if err != nil {
+			return reflect.Value{}, nil, err
+		}
+		nulls := make([]bool, rows)
+		for i, f := range flags {
+			nulls[i] = f != 0
+		}
+		values, _, err := r.readColumn(t.Args[0], rows)
+		return values, nulls, err
+	case "LowCardinality":
+		return r.readLowCardinality(t, rows)
+	case "Array", "Nested", "Map":
+		offsets := make([]uint64, rows)
+		if err := binary.Read(r.r, binary.LittleEndian, offsets); err != nil {
+			return reflect.Value{}, nil, r.streamError(err)
+		}
+		total := 0
+		if rows > 0 {
+			if offsets[rows-1] > math.MaxInt32 {
+				return reflect.Value{}, nil, fmt.Errorf("Array size %d is too big", offsets[rows-1])
+			}
+			total = int(offsets[rows-1])
+		}
+		if t.Name == "Map" {
+			return r.readMap(t, offsets, total)
+		}
+		elem := t.Args[0]
+		if t.Name == "Nested" {
+			elem = &ColumnType{Name: "Tuple", Args: t.Args, Names: t.Names, raw: t.raw}
+		}
+		items, err := r.readNestedColumn(elem, total)
+		if err != nil {
+			return reflect.Value{}, nil, err
+		}
+		res := reflect.MakeSlice(reflect.SliceOf(items.Type()), rows, rows)
+		prev := 0
+		for i, off := range offsets {
+			if int(off) < prev || int(off) > total {
+				return reflect.Value{}, nil, fmt.Errorf("Bad array offset %d", off)
+			}
+			res.Index(i).Set(items.Slice3(prev, int(off), int(off)))
+			prev = int(off)
+		}
+		return res, nil, nil
+	case "Tuple":
+		elems := make([]reflect.Value, len(t.Args))
+		nulls := make([][]bool, len(t.Args))
+		for j, arg := range t.Args {
+			var err error
+			if elems[j], nulls[j], err = r.readColumn(arg, rows); err != nil {
+				return reflect.Value{}, nil, fmt.Errorf("element %d: %v", j, err)
+			}
+		}
+		res := make([]Tuple, rows)
+		for i := range res {
+			res[i] = make(Tuple, len(t.Args))
+			for j := range t.Args {
+				if nulls[j] == nil || !nulls[j][i] {
+					res[i][j] = elems[j].Index(i).Interface()
+				}
+			}
+		}
+		return reflect.ValueOf(res), nil, nil
+	}
+
+	res := reflect.MakeSlice(reflect.SliceOf(naturalType(t)), rows, rows)
+	switch t.Name {
+	case "Int8", "Int16", "Int32", "Int64", "UInt8", "UInt16", "UInt32", "UInt64", "Float32", "Float64", "Bool":
+		if err := binary.Read(r.r, binary.LittleEndian, res.Interface()); err != nil {
+			return res, nil, r.streamError(err)
+		}
+		return res, nil, nil
+	case "String":
+		values := res.Interface().([]string)
+		for i := range values {
+			b, err := r.readBytes()
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+			values[i] = string(b)
+		}
+		return res, nil, nil
+	case "Date", "Date32", "DateTime", "DateTime64":
+		values := res.Interface().([]time.Time)
+		for i := range values {
+			tm, err := r.readTime(t)
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+			values[i] = tm
+		}
+		return res, nil, nil
+	}
+	for i := 0; i < rows; i++ {
+		if err := r.decode(t, res.Index(i)); err != nil {
+			return reflect.Value{}, nil, err
+		}
+	}
+	return res, nil, nil
+}
+
+// readNestedColumn reads column inside Array or Map, Nullable values are returned as pointers
+func (r *binaryReader) readNestedColumn(t *ColumnType, rows int) (reflect.Value, error) {
+	values, nulls, err := r.readColumn(t, rows)
+	if err != nil || nulls == nil {
+		return values, err
+	}
+	res := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(values.Type().Elem())), rows, rows)
+	for i, null := range nulls {
+		if !null {
+			res.Index(i).Set(values.Index(i).Addr())
+		}
+	}
+	return res, nil
+}
+
+func (r *binaryReader) readMap(t *ColumnType, offsets []uint64, total int) (reflect.Value, []bool, error) {
+	keys, err := r.readNestedColumn(t.Args[0], total)
+	if err != nil {
+		return reflect.Value{}, nil, fmt.Errorf("keys: %v", err)
+	}
+	values, err := r.readNestedColumn(t.Args[1], total)
+	if err != nil {
+		return reflect.Value{}, nil, fmt.Errorf("values: %v", err)
+	}
+	if !keys.Type().Elem().Comparable() {
+		return reflect.Value{}, nil, fmt.Errorf("Type %s cannot be used as map key", t.Args[0])
+	}
+
+	mapType := reflect.MapOf(keys.Type().Elem(), values.Type().Elem())
+	res := reflect.MakeSlice(reflect.SliceOf(mapType), len(offsets), len(offsets))
+	prev := 0
+	for i, off := range offsets {
+		if int(off) < prev || int(off) > total {
+			return reflect.Value{}, nil, fmt.Errorf("Bad map offset %d", off)
+		}
+		m := reflect.MakeMapWithSize(mapType, int(off)-prev)
+		for j := prev; j < int(off); j++ {
+			m.SetMapIndex(keys.Index(j), values.Index(j))
+		}
+		res.Index(i).Set(m)
+		prev = int(off)
+	}
+	return res, nil, nil
+}
+
+func (r *binaryReader) readLowCardinality(t *ColumnType, rows int) (reflect.Value, []bool, error) {
+	inner := t.Args[0]
+	nullable := inner.Name == "Nullable"
+	if nullable {
+		inner = inner.Args[0]
+	}
+
+	res := reflect.MakeSlice(reflect.SliceOf(naturalType(inner)), rows, rows)
+	var nulls []bool
+	if nullable {
+		nulls = make([]bool, rows)
+	}
+
+	var dict reflect.Value
+	for pos := 0; pos < rows; {
+		flags, err := r.readUint(64)
+		if err != nil {
+			return reflect.Value{}, nil, err
+		}
+		if flags&lowCardinalityAdditionalKeys == 0 {
+			return reflect.Value{}, nil, errors.New("LowCardinality with global dictionary is not supported")
+		}
+		if flags&lowCardinalityUpdateDictionary != 0 || !dict.IsValid() {
+			size, err := r.readUint(64)
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+			if size > math.MaxInt32 {
+				return reflect.Value{}, nil, fmt.Errorf("LowCardinality dictionary size %d is too big", size)
+			}
+			if dict, _, err = r.readColumn(inner, int(size)); err != nil {
+				return reflect.Value{}, nil, err
+			}
+		}
+
+		n, err := r.readUint(64)
+		if err != nil {
+			return reflect.Value{}, nil, err
+		}
+		if n > uint64(rows-pos) {
+			return reflect.Value{}, nil, fmt.Errorf("LowCardinality has %d rows, expected %d", n, rows-pos)
+		}
+		bits := 8 << (flags & 0xff)
+		for i := pos; i < pos+int(n); i++ {
+			key, err := r.readUint(bits)
+			if err != nil {
+				return reflect.Value{}, nil, err
+			}
+			if key >= uint64(dict.Len()) {
+				return reflect.Value{}, nil, fmt.Errorf("LowCardinality key %d is out of dictionary", key)
+			}
+			// first key of Nullable dictionary is NULL
+			if nullable && key == 0 {
+				nulls[i] = true
+				continue
+			}
+			res.Index(i).Set(dict.Index(int(key)))
+		}
+		pos += int(n)
+	}
+	return res, nulls, nil
+}
+
+// BlockWriter encodes column vectors in Native format, rows of Nullable columns are nil for NULL
+type BlockWriter struct {
+	w      io.Writer
+	schema Schema
+	types  []*ColumnType
+	buf    []byte
+}
+
+// NewBlockWriter creates writer for schema, it fails on unsupported column types
+func NewBlockWriter(w io.Writer, schema Schema) (*BlockWriter, error) {
+	if len(schema) == 0 {
+		return nil, errors.New("Schema is empty")
+	}
+	types := make([]*ColumnType, len(schema))
+	for i, col := range schema {
+		t, err := parseColumnType(col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		types[i] = t
+	}
+	return &BlockWriter{w: w, schema: schema, types: types}, nil
+}
+
+// WriteBlock writes one block, columns are slices of equal length in schema order
+func (w *BlockWriter) WriteBlock(columns ...interface{}) error {
+	if len(columns) != len(w.types) {
+		return fmt.Errorf("Block has %d columns, schema has %d", len(columns), len(w.types))
+	}
+	values := make([]reflect.Value, len(columns))
+	for i, col := range columns {
+		v := reflect.ValueOf(col)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return fmt.Errorf("column %s: type %T is not a slice", w.schema[i].Name, col)
+		}
+		values[i] = v
+	}
+	rows := values[0].Len()
+	for i, v := range values {
+		if v.Len() != rows {
+			return fmt.Errorf("column %s has %d rows, expected %d", w.schema[i].Name, v.Len(), rows)
+		}
+	}
+
+	buf := binary.AppendUvarint(w.buf[:0], uint64(len(columns)))
+	buf = binary.AppendUvarint(buf, uint64(rows))
+	var err error
+	for i, t := range w.types {
+		buf = appendString(buf, w.schema[i].Name)
+		buf = appendString(buf, w.schema[i].Type)
+		if rows == 0 {
+			continue
+		}
+		buf = appendPrefix(buf, t)
+		if buf, err = appendColumn(buf, t, values[i]); err != nil {
+			return fmt.Errorf("column %s: %v", w.schema[i].Name, err)
+		}
+	}
+	w.buf = buf
+	_, err = w.w.Write(buf)
+	return err
+}
+
+func appendPrefix(buf []byte, t *ColumnType) []byte {
+	if t.Name == "LowCardinality" {
+		return binary.LittleEndian.AppendUint64(buf, lowCardinalitySharedKeys)
+	}
+	for _, arg := range t.Args {
+		buf = appendPrefix(buf, arg)
+	}
+	return buf
+}
+
+// appendColumn encodes slice v as column of type t
+func appendColumn(buf []byte, t *ColumnType, v reflect.Value) ([]byte, error) {
+	rows := v.Len()
+	switch t.Name {
+	case "Nullable":
+		items := make([]interface{}, rows)
+		for i := range items {
+			item, null, err := binaryValue(v.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+			if null {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+				items[i] = item
+			}
+		}
+		var err error
+		for i, item := range items {
+			if item == nil {
+				buf = appendZero(buf, t.Args[0])
+			} else if buf, err = appendBinary(buf, t.Args[0], item); err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+		}
+		return buf, nil
+	case "LowCardinality":
+		return appendLowCardinality(buf, t, v)
+	case "Array", "Nested":
+		elem := t.Args[0]
+		if t.Name == "Nested" {
+			elem = &ColumnType{Name: "Tuple", Args: t.Args, Names: t.Names, raw: t.raw}
+		}
+		var items reflect.Value
+		if v.Type().Elem().Kind() == reflect.Slice {
+			items = reflect.MakeSlice(v.Type().Elem(), 0, rows)
+		} else {
+			items = reflect.ValueOf([]interface{}{})
+		}
+		for i := 0; i < rows; i++ {
+			row := indirectValue(v.Index(i))
+			if row.Kind() != reflect.Slice && row.Kind() != reflect.Array {
+				return nil, fmt.Errorf("row %d: type %s cannot be written as %s", i, row.Type(), t)
+			}
+			if row.Type() == items.Type() {
+				items = reflect.AppendSlice(items, row)
+			} else {
+				for j := 0; j < row.Len(); j++ {
+					items = reflect.Append(items, row.Index(j))
+				}
+			}
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(items.Len()))
+		}
+		return appendColumn(buf, elem, items)
+	case "Map":
+		var keys, values []interface{}
+		for i := 0; i < rows; i++ {
+			row := indirectValue(v.Index(i))
+			if row.Kind() != reflect.Map {
+				return nil, fmt.Errorf("row %d: type %s cannot be written as %s", i, row.Type(), t)
+			}
+			iter := row.MapRange()
+			for iter.Next() {
+				keys = append(keys, iter.Key().Interface())
+				values = append(values, iter.Value().Interface())
+			}
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(len(keys)))
+		}
+		buf, err := appendColumn(buf, t.Args[0], reflect.ValueOf(keys))
+		if err != nil {
+			return nil, fmt.Errorf("keys: %v", err)
+		}
+		if buf, err = appendColumn(buf, t.Args[1], reflect.ValueOf(values)); err != nil {
+			return nil, fmt.Errorf("values: %v", err)
+		}
+		return buf, nil
+	case "Tuple":
+		elems := make([][]interface{}, len(t.Args))
+		for j := range elems {
+			elems[j] = make([]interface{}, rows)
+		}
+		for i := 0; i < rows; i++ {
+			items, err := binaryTuple(indirectValue(v.Index(i)).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+			if len(items) != len(t.Args) {
+				return nil, fmt.Errorf("row %d: tuple of %d elements cannot be written as %s", i, len(items), t)
+			}
+			for j, item := range items {
+				elems[j][i] = item
+			}
+		}
+		var err error
+		for j, arg := range t.Args {
+			if buf, err = appendColumn(buf, arg, reflect.ValueOf(elems[j])); err != nil {
+				return nil, fmt.Errorf("element %d: %v", j, err)
+			}
+		}
+		return buf, nil
+	}
+
+	// most common vectors are written without boxing values into interfaces
+	switch col := v.Interface().(type) {
+	case []uint64:
+		if t.Name == "UInt64" {
+			for _, x := range col {
+				buf = binary.LittleEndian.AppendUint64(buf, x)
+			}
+			return buf, nil
+		}
+	case []int64:
+		if t.Name == "Int64" {
+			for _, x := range col {
+				buf = binary.LittleEndian.AppendUint64(buf, uint64(x))
+			}
+			return buf, nil
+		}
+	case []uint32:
+		if t.Name == "UInt32" {
+			for _, x := range col {
+				buf = binary.LittleEndian.AppendUint32(buf, x)
+			}
+			return buf, nil
+		}
+	case []int32:
+		if t.Name == "Int32" {
+			for _, x := range col {
+				buf = binary.LittleEndian.AppendUint32(buf, uint32(x))
+			}
+			return buf, nil
+		}
+	case []float64:
+		if t.Name == "Float64" {
+			for _, x := range col {
+				buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(x))
+			}
+			return buf, nil
+		}
+	case []float32:
+		if t.Name == "Float32" {
+			for _, x := range col {
+				buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(x))
+			}
+			return buf, nil
+		}
+	case []string:
+		if t.Name == "String" {
+			for _, x := range col {
+				buf = appendString(buf, x)
+			}
+			return buf, nil
+		}
+	}
+
+	var err error
+	for i := 0; i < rows; i++ {
+		if buf, err = appendBinary(buf, t, v.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+	}
+	return buf, nil
+}
+
+// appendLowCardinality writes dictionary of unique values followed by their indexes
+func appendLowCardinality(buf []byte, t *ColumnType, v reflect.Value) ([]byte, error) {
+	rows := v.Len()
+	if rows == 0 {
+		return buf, nil
+	}
+	inner := t.Args[0]
+	nullable := inner.Name == "Nullable"
+	if nullable {
+		inner = inner.Args[0]
+	}
+
+	var (
+		dict    []byte
+		scratch []byte
+		size    int
+		err     error
+	)
+	keys := make(map[string]int)
+	indexes := make([]int, rows)
+	if nullable {
+		dict = appendZero(dict, inner)
+		size = 1
+	}
+	for i := range indexes {
+		item, null, err := binaryValue(v.Index(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		if null {
+			if !nullable {
+				return nil, fmt.Errorf("row %d: NULL is not allowed for %s", i, t)
+			}
+			continue
+		}
+		if scratch, err = appendBinary(scratch[:0], inner, item); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		key, ok := keys[string(scratch)]
+		if !ok {
+			key = size
+			keys[string(scratch)] = key
+			dict = append(dict, scratch...)
+			size++
+		}
+		indexes[i] = key
+	}
+
+	var keyType uint64
+	switch {
+	case size <= math.MaxUint8+1:
+		keyType = 0
+	case size <= math.MaxUint16+1:
+		keyType = 1
+	case size <= math.MaxUint32+1:
+		keyType = 2
+	default:
+		keyType = 3
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, keyType|lowCardinalityAdditionalKeys|lowCardinalityUpdateDictionary)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(size))
+	buf = append(buf, dict...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(rows))
+	for _, idx := range indexes {
+		buf = appendUint(buf, uint64(idx), 8<<keyType)
+	}
+	return buf, err
+}
+
+// appendZero writes default value in place of NULL
+func appendZero(buf []byte, t *ColumnType) []byte {
+	size := 0
+	switch t.Name {
+	case "String":
+		size = 1
+	case "FixedString":
+		size = t.Size
+	case "Decimal":
+		size = decimalBits(t.Precision) / 8
+	case "Bool":
+		size = 1
+	case "Date":
+		size = 2
+	case "Date32", "DateTime", "IPv4":
+		size = 4
+	case "DateTime64":
+		size = 8
+	case "UUID", "IPv6":
+		size = 16
+	default:
+		size = typeBits(t.Name) / 8
+	}
+	return append(buf, make([]byte, size)...)
+}
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// NativeInsert streams blocks into INSERT ... FORMAT Native request:
+//
+//	ins, _ := clickhouse.NewNativeInsert(conn, "points", clickhouse.Schema{{"x", "Float64"}, {"y", "Float64"}})
+//	err := ins.WriteBlock(xs, ys)
+//	err = ins.Close()
+type NativeInsert struct {
+	w      *BlockWriter
+	stream *insertStream
+}
+
+// NewNativeInsert starts insert request with columns of schema
+func NewNativeInsert(conn Connector, table string, schema Schema) (*NativeInsert, error) {
+	if conn == nil {
+		return nil, errors.New("Connection pointer is nil")
+	}
+	stream := &insertStream{}
+	w, err := NewBlockWriter(stream, schema)
+	if err != nil {
+		return nil, err
+	}
+	stream.start(conn, NewQuery(fmt.Sprintf("INSERT INTO %s (%s) FORMAT %s", table, strings.Join(schema.Names(), ","), FormatNative)))
+	return &NativeInsert{w: w, stream: stream}, nil
+}
+
+// WriteBlock encodes and sends one block of columns
+func (i *NativeInsert) WriteBlock(columns ...interface{}) error {
+	return i.w.WriteBlock(columns...)
+}
+
+// Close finishes request body and waits for server response
+func (i *NativeInsert) Close() error {
+	return i.stream.Close()
+}
+
+// Abort breaks request, so server does not commit rows which were already sent
+func (i *NativeInsert) Abort(err error) {
+	i.stream.Abort(err)
+}
@@ -0,0 +1,240 @@
+package clickhouse
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nativeResponse(schema Schema, blocks ...[]interface{}) string {
+	buf := &bytes.Buffer{}
+	w, err := NewBlockWriter(buf, schema)
+	if err != nil {
+		panic(err)
+	}
+	for _, columns := range blocks {
+		if err = w.WriteBlock(columns...); err != nil {
+			panic(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestBlockWriter_Encoding(t *testing.T) {
+	cases := []struct {
+		typ      string
+		column   interface{}
+		expected []byte
+	}{
+		{"UInt16", []uint16{1, 2}, []byte{1, 0, 2, 0}},
+		{"Int64", []int64{-1}, bytes.Repeat([]byte{0xff}, 8)},
+		{"String", []string{"a", ""}, []byte{1, 'a', 0}},
+		{"Nullable(UInt8)", []interface{}{nil, 5}, []byte{1, 0, 0, 5}},
+		{"Nullable(String)", []*string{nil}, []byte{1, 0}},
+		{"Array(UInt8)", [][]uint8{{1}, {}, {2, 3}}, []byte{1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3}},
+		{"Tuple(UInt8, String)", []Tuple{{1, "a"}, {2, "b"}}, []byte{1, 2, 1, 'a', 1, 'b'}},
+		{"Map(String, UInt8)", []map[string]int{{"a": 1}}, []byte{1, 0, 0, 0, 0, 0, 0, 0, 1, 'a', 1}},
+		{"LowCardinality(String)", []string{"a", "b", "a"}, []byte{
+			1, 0, 0, 0, 0, 0, 0, 0, // prefix
+			0, 6, 0, 0, 0, 0, 0, 0, // UInt8 keys, additional keys, update dictionary
+			2, 0, 0, 0, 0, 0, 0, 0, 1, 'a', 1, 'b',
+			3, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0,
+		}},
+		{"LowCardinality(Nullable(String))", []interface{}{"a", nil}, []byte{
+			1, 0, 0, 0, 0, 0, 0, 0,
+			0, 6, 0, 0, 0, 0, 0, 0,
+			2, 0, 0, 0, 0, 0, 0, 0, 0, 1, 'a',
+			2, 0, 0, 0, 0, 0, 0, 0, 1, 0,
+		}},
+	}
+	for _, c := range cases {
+		buf := &bytes.Buffer{}
+		w, err := NewBlockWriter(buf, Schema{{"c", c.typ}})
+		if !assert.NoError(t, err, c.typ) {
+			continue
+		}
+		if assert.NoError(t, w.WriteBlock(c.column), c.typ) {
+			header := append([]byte{1, byte(reflect.ValueOf(c.column).Len()), 1, 'c', byte(len(c.typ))}, c.typ...)
+			assert.Equal(t, append(header, c.expected...), buf.Bytes(), c.typ)
+		}
+	}
+}
+
+func TestBlockWriter_Errors(t *testing.T) {
+	w, err := NewBlockWriter(&bytes.Buffer{}, Schema{{"a", "UInt8"}, {"b", "String"}})
+	assert.NoError(t, err)
+	assert.Error(t, w.WriteBlock([]uint8{1}))
+	assert.Error(t, w.WriteBlock([]uint8{1}, []string{"a", "b"}))
+	assert.Error(t, w.WriteBlock(1, []string{"a"}))
+	assert.Error(t, w.WriteBlock([]int{300}, []string{"a"}))
+	assert.Error(t, w.WriteBlock([]uint8{1}, []interface{}{nil}))
+
+	w, _ = NewBlockWriter(&bytes.Buffer{}, Schema{{"a", "LowCardinality(String)"}})
+	assert.Error(t, w.WriteBlock([]interface{}{nil}))
+
+	_, err = NewBlockWriter(&bytes.Buffer{}, Schema{{"a", "Object('json')"}})
+	assert.Error(t, err)
+	_, err = NewBlockWriter(&bytes.Buffer{}, nil)
+	assert.Error(t, err)
+}
+
+func TestBlockIter(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	schema := Schema{
+		{"id", "UInt64"}, {"price", "Float64"}, {"name", "LowCardinality(String)"}, {"created", "DateTime"},
+		{"amount", "Decimal(18, 2)"}, {"note", "Nullable(String)"}, {"tags", "Array(Nullable(Int32))"},
+		{"attrs", "Map(String, UInt8)"}, {"pair", "Tuple(String, Nullable(UInt8))"}, {"ok", "Bool"},
+		{"e", "Enum8('a' = 1, 'b' = 2)"}, {"lcn", "LowCardinality(Nullable(String))"},
+		{"n", "Nested(k String, v UInt16)"},
+	}
+	resp := nativeResponse(schema,
+		[]interface{}{
+			[]uint64{1, 2}, []float64{0.5, 1.5}, []string{"x", "x"}, []time.Time{tm, tm.Add(time.Hour)},
+			[]Decimal{NewDecimal(150, 2), NewDecimal(-1, 2)}, []interface{}{"n", nil}, [][]interface{}{{1, nil}, {}},
+			[]map[string]int{{"a": 1}, {}}, []Tuple{{"p", 1}, {"q", nil}}, []bool{true, false},
+			[]string{"a", "b"}, []interface{}{nil, "v"},
+			[][]Tuple{{{"k1", 1}, {"k2", 2}}, nil},
+		},
+		[]interface{}{
+			[]uint64{}, []float64{}, []string{}, []time.Time{}, []Decimal{}, []string{}, [][]int32{},
+			[]map[string]int{}, []Tuple{}, []bool{}, []string{}, []string{}, [][]Tuple{},
+		},
+		[]interface{}{
+			[]uint64{3}, []float64{2.5}, []string{"y"}, []time.Time{tm}, []string{"0"}, []string{"m"}, [][]int32{{3}},
+			[]map[string]uint8{{"b": 2}}, []Tuple{{"r", 3}}, []bool{true}, []int{1}, []string{"w"}, [][]Tuple{{}},
+		},
+	)
+
+	blocks := NewQuery("SELECT *").Blocks(NewConn(getHost(), getMockTransport(resp)))
+	assert.NoError(t, blocks.Error())
+
+	assert.True(t, blocks.Next())
+	b := blocks.Block()
+	assert.Equal(t, schema, b.Columns())
+	assert.Equal(t, 2, b.Rows())
+
+	ids, err := b.Uint64Column("id")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids)
+	prices, _ := b.Float64Column("price")
+	assert.Equal(t, []float64{0.5, 1.5}, prices)
+	names, _ := b.StringColumn("name")
+	assert.Equal(t, []string{"x", "x"}, names)
+	created, _ := b.TimeColumn("created")
+	assert.True(t, tm.Equal(created[0]))
+	assert.True(t, tm.Add(time.Hour).Equal(created[1]))
+	amounts, _ := b.DecimalColumn("amount")
+	assert.Equal(t, "1.50", amounts[0].String())
+	assert.Equal(t, "-0.01", amounts[1].String())
+	notes, _ := b.StringColumn("note")
+	assert.Equal(t, []string{"n", ""}, notes)
+	assert.Equal(t, []bool{false, true}, b.Nulls("note"))
+	assert.Nil(t, b.Nulls("id"))
+
+	tags, _ := b.Column("tags")
+	one := int32(1)
+	assert.Equal(t, [][]*int32{{&one, nil}, {}}, tags)
+	attrs, _ := b.Column("attrs")
+	assert.Equal(t, []map[string]uint8{{"a": 1}, {}}, attrs)
+	pair, _ := b.Column("pair")
+	assert.Equal(t, []Tuple{{"p", uint8(1)}, {"q", nil}}, pair)
+	ok, _ := b.BoolColumn("ok")
+	assert.Equal(t, []bool{true, false}, ok)
+	e, _ := b.StringColumn("e")
+	assert.Equal(t, []string{"a", "b"}, e)
+	lcn, _ := b.StringColumn("lcn")
+	assert.Equal(t, []string{"", "v"}, lcn)
+	assert.Equal(t, []bool{true, false}, b.Nulls("lcn"))
+	n, _ := b.Column("n")
+	assert.Equal(t, [][]Tuple{{{"k1", uint16(1)}, {"k2", uint16(2)}}, {}}, n)
+
+	_, err = b.Int32Column("id")
+	assert.Error(t, err)
+	_, err = b.Column("missing")
+	assert.Error(t, err)
+
+	// empty block is skipped
+	assert.True(t, blocks.Next())
+	assert.Equal(t, 1, blocks.Block().Rows())
+	ids, _ = blocks.Block().Uint64Column("id")
+	assert.Equal(t, []uint64{3}, ids)
+
+	assert.False(t, blocks.Next())
+	assert.NoError(t, blocks.Error())
+}
+
+func TestBlockIter_Errors(t *testing.T) {
+	resp := nativeResponse(Schema{{"a", "UInt32"}}, []interface{}{[]uint32{1, 2}})
+
+	blocks := NewQuery("SELECT *").Blocks(NewConn(getHost(), getMockTransport(resp[:len(resp)-1])))
+	assert.False(t, blocks.Next())
+	assert.Error(t, blocks.Error())
+
+	// exception sent in the middle of block
+	strResp := nativeResponse(Schema{{"s", "String"}}, []interface{}{[]string{"a", "b"}})
+	blocks = NewQuery("SELECT *").Blocks(NewConn(getHost(), getMockTransport(strResp[:len(strResp)-2]+"Code: 241. DB::Exception: Memory limit (for query) exceeded\n")))
+	assert.False(t, blocks.Next())
+	if assert.IsType(t, &DbError{}, blocks.Error()) {
+		assert.Equal(t, 241, blocks.Error().(*DbError).Code())
+	}
+
+	blocks = NewQuery("SELECT *").Blocks(NewConn(getHost(), getMockTransport("Code: 62, e.displayText() = DB::Exception: Syntax error")))
+	assert.Error(t, blocks.Error())
+	assert.Equal(t, 62, blocks.Error().(*DbError).Code())
+	assert.False(t, blocks.Next())
+
+	blocks = NewQuery("SELECT *").Blocks(nil)
+	assert.Error(t, blocks.Error())
+	assert.False(t, blocks.Next())
+}
+
+func TestNativeInsert(t *testing.T) {
+	handler := &insertHandler{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn := NewConn(server.URL, NewHttpTransport())
+	schema := Schema{{"id", "UInt8"}, {"name", "String"}}
+	ins, err := NewNativeInsert(conn, "t", schema)
+	assert.NoError(t, err)
+	assert.NoError(t, ins.WriteBlock([]uint8{1, 2}, []string{"a", "b"}))
+	assert.NoError(t, ins.WriteBlock([]uint8{3}, []string{"c"}))
+	assert.NoError(t, ins.Close())
+
+	assert.Equal(t, "INSERT INTO t (id,name) FORMAT Native", handler.query)
+	assert.Equal(t, nativeResponse(schema, []interface{}{[]uint8{1, 2}, []string{"a", "b"}}, []interface{}{[]uint8{3}, []string{"c"}}), string(handler.body))
+
+	handler.resp = "Code: 27, e.displayText() = DB::Exception: Cannot parse input, e.what() = DB::Exception"
+	ins, err = NewNativeInsert(conn, "t", schema)
+	assert.NoError(t, err)
+	assert.NoError(t, ins.WriteBlock([]uint8{1}, []string{"a"}))
+	err = ins.Close()
+	assert.Error(t, err)
+	assert.Equal(t, 27, err.(*DbError).Code())
+
+	_, err = NewNativeInsert(nil, "t", schema)
+	assert.Error(t, err)
+}
+
+func BenchmarkBlockIter(b *testing.B) {
+	rows := benchmarkRows()
+	columns := []interface{}{make([]uint64, len(rows)), make([]float64, len(rows)), make([]string, len(rows)), make([]time.Time, len(rows))}
+	for i, row := range rows {
+		columns[0].([]uint64)[i] = row[0].(uint64)
+		columns[1].([]float64)[i] = row[1].(float64)
+		columns[2].([]string)[i] = row[2].(string)
+		columns[3].([]time.Time)[i] = row[3].(time.Time)
+	}
+	conn := NewConn(getHost(), getMockTransport(nativeResponse(benchmarkSchema, columns)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		blocks := NewQuery("SELECT *").Blocks(conn)
+		for blocks.Next() {
+		}
+	}
+}
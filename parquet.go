@@ -0,0 +1,256 @@
+package clickhouse
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const FormatParquet = "Parquet"
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// ExportParquet streams query result in Parquet format into w and returns amount of written bytes.
+// Exception sent by server after part of file is returned as *DbError, file is broken then.
+// Use ParquetRowCount to check written file
+func (c *Conn) ExportParquet(ctx context.Context, q Query, w io.Writer) (int64, error) {
+	q.Stmt += " FORMAT " + FormatParquet
+	q.SetContext(ctx)
+	body, err := c.Stream(q, false)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return copyResponse(w, body)
+}
+
+// ImportParquet streams Parquet file into table, columns are matched by name
+func (c *Conn) ImportParquet(ctx context.Context, table string, r io.Reader) error {
+	return c.InsertFrom(ctx, table, FormatParquet, r)
+}
+
+// ParquetRowCount reads amount of rows from footer of Parquet file with given size, e.g. to compare it
+// with count() of archived partition:
+//
+//	n, err := conn.ExportParquet(ctx, clickhouse.NewQuery("SELECT * FROM events WHERE toYYYYMM(date) = 202001"), f)
+//	rows, err := clickhouse.ParquetRowCount(f, n)
+func ParquetRowCount(r io.ReaderAt, size int64) (int64, error) {
+	if size < int64(2*len(parquetMagic)+4) {
+		return 0, errors.New("File is too small for Parquet")
+	}
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return 0, err
+	}
+	if string(tail[4:]) != parquetMagic {
+		return 0, errors.New("File has no Parquet footer, it may be truncated")
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(tail))
+	if footerSize > size-int64(len(parquetMagic))-8 {
+		return 0, fmt.Errorf("Bad Parquet footer size %d", footerSize)
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-8-footerSize); err != nil {
+		return 0, err
+	}
+	return parquetNumRows(footer)
+}
+
+// parquetNumRows reads num_rows field of FileMetaData, it is encoded with Thrift compact protocol
+func parquetNumRows(footer []byte) (int64, error) {
+	r := &thriftReader{b: footer}
+	var id int16
+	for {
+		typ, err := r.readFieldHeader(&id)
+		if err != nil {
+			return 0, err
+		}
+		if typ == thriftStop {
+			return 0, errors.New("Parquet footer has no num_rows")
+		}
+		if id == 3 && typ == thriftI64 {
+			return r.readInt()
+		}
+		if err = r.skip(typ); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Thrift compact protocol types
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// limits protect from corrupted footers
+const (
+	thriftMaxDepth  = 64
+	thriftMaxLength = 1 << 30
+)
+
+// thriftReader reads fields of Thrift compact protocol structs, only what is needed to skip them
+type thriftReader struct {
+	b     []byte
+	pos   int
+	depth int
+}
+
+var errThriftEOF = errors.New("Parquet footer is truncated")
+
+func (r *thriftReader) readByte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, errThriftEOF
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *thriftReader) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, errThriftEOF
+	}
+	r.pos += n
+	return v, nil
+}
+
+// readInt reads zigzag encoded i16, i32 or i64
+func (r *thriftReader) readInt() (int64, error) {
+	v, err := r.readUvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) readLength() (int, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > thriftMaxLength || int(n) > len(r.b)-r.pos {
+		return 0, errThriftEOF
+	}
+	return int(n), nil
+}
+
+// readFieldHeader returns type of next field and updates id, field ids are delta encoded
+func (r *thriftReader) readFieldHeader(id *int16) (byte, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	typ := b & 0x0f
+	if typ == thriftStop {
+		return typ, nil
+	}
+	if delta := int16(b >> 4); delta != 0 {
+		*id += delta
+		return typ, nil
+	}
+	v, err := r.readInt()
+	*id = int16(v)
+	return typ, err
+}
+
+func (r *thriftReader) skip(typ byte) error {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// value of struct field is stored in its type
+		return nil
+	case thriftByte:
+		_, err := r.readByte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := r.readUvarint()
+		return err
+	case thriftDouble:
+		if len(r.b)-r.pos < 8 {
+			return errThriftEOF
+		}
+		r.pos += 8
+		return nil
+	case thriftBinary:
+		n, err := r.readLength()
+		r.pos += n
+		return err
+	}
+
+	if r.depth++; r.depth > thriftMaxDepth {
+		return errors.New("Parquet footer nesting is too deep")
+	}
+	defer func() { r.depth-- }()
+
+	switch typ {
+	case thriftList, thriftSet:
+		b, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		size := int(b >> 4)
+		if size == 15 {
+			if size, err = r.readLength(); err != nil {
+				return err
+			}
+		}
+		return r.skipElements(b&0x0f, size)
+	case thriftMap:
+		size, err := r.readLength()
+		if err != nil || size == 0 {
+			return err
+		}
+		types, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err = r.skipElements(types>>4, 1); err != nil {
+				return err
+			}
+			if err = r.skipElements(types&0x0f, 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		var id int16
+		for {
+			t, err := r.readFieldHeader(&id)
+			if err != nil || t == thriftStop {
+				return err
+			}
+			if err = r.skip(t); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("Unknown Thrift type %d in Parquet footer", typ)
+}
+
+// skipElements skips collection items, booleans take one byte there unlike struct fields
+func (r *thriftReader) skipElements(typ byte, size int) error {
+	for i := 0; i < size; i++ {
+		var err error
+		if typ == thriftTrue || typ == thriftFalse {
+			_, err = r.readByte()
+		} else {
+			err = r.skip(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
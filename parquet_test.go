@@ -0,0 +1,97 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parquetFile makes file with FileMetaData footer encoded by Thrift compact protocol
+func parquetFile(rows int64) []byte {
+	footer := []byte{
+		0x15, 0x02, // 1: version i32 = 1
+		0x19, 0xfc, 0x12, // 2: schema list of 15+ structs
+	}
+	for i := 0; i < 18; i++ {
+		footer = append(footer,
+			0x48, 0x02, 'i', 'd', // 4: name binary
+			0x15, 0x0c, // 5: num_children i32
+			0x11,                                     // 6: bool field true
+			0x07, 0x0e, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, // 7 (long form): double
+			0x1b, 0x01, 0x88, 0x01, 'k', 0x01, 'v', // 8: map<binary, binary>
+			0x1a, 0x11, 0x01, // 9: set of bools
+			0x00,
+		)
+	}
+	footer = append(footer, 0x06, 0x06) // 3 (long form): num_rows i64
+	footer = binary.AppendUvarint(footer, uint64(rows<<1)^uint64(rows>>63))
+	footer = append(footer, 0x00)
+
+	res := []byte("PAR1data")
+	res = append(res, footer...)
+	res = binary.LittleEndian.AppendUint32(res, uint32(len(footer)))
+	return append(res, "PAR1"...)
+}
+
+func TestParquetRowCount(t *testing.T) {
+	file := parquetFile(1000)
+	rows, err := ParquetRowCount(bytes.NewReader(file), int64(len(file)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), rows)
+
+	_, err = ParquetRowCount(bytes.NewReader(file), int64(len(file)-1))
+	assert.Error(t, err)
+	_, err = ParquetRowCount(strings.NewReader("PAR1"), 4)
+	assert.Error(t, err)
+
+	// footer size points outside of file
+	broken := append([]byte{}, file...)
+	binary.LittleEndian.PutUint32(broken[len(broken)-8:], uint32(len(file)))
+	_, err = ParquetRowCount(bytes.NewReader(broken), int64(len(broken)))
+	assert.Error(t, err)
+
+	// footer without num_rows
+	broken = append([]byte("PAR1"), 0x15, 0x02, 0x00)
+	broken = binary.LittleEndian.AppendUint32(broken, 3)
+	broken = append(broken, "PAR1"...)
+	_, err = ParquetRowCount(bytes.NewReader(broken), int64(len(broken)))
+	assert.Error(t, err)
+}
+
+func TestConn_Parquet(t *testing.T) {
+	file := parquetFile(2)
+	var query string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if query = r.URL.Query().Get("query"); query != "" {
+			body = data
+			return
+		}
+		query = string(data)
+		w.Write(file)
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	buf := &bytes.Buffer{}
+	n, err := conn.ExportParquet(context.Background(), NewQuery("SELECT * FROM events"), buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(file)), n)
+	assert.Equal(t, "SELECT * FROM events FORMAT Parquet", query)
+	rows, err := ParquetRowCount(bytes.NewReader(buf.Bytes()), n)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+
+	err = conn.ImportParquet(context.Background(), "events_archive", bytes.NewReader(file))
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO events_archive FORMAT Parquet", query)
+	assert.Equal(t, file, body)
+}
@@ -1,10 +1,16 @@
 package clickhouse
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
+	"time"
 )
 
 type External struct {
@@ -23,6 +29,21 @@ type Query struct {
 	args      []interface{}
 	externals []External
 	params    url.Values
+	// literals are rendered args, BuildMultiInsert sets them so values are marshaled once
+	literals []string
+	location *time.Location
+	// body is sent as request data after statement, e.g. rows of INSERT ... FORMAT RowBinary
+	body io.Reader
+	ctx  context.Context
+	// header is sent with http request, response gets headers of response
+	header   http.Header
+	response *responseInfo
+	// kind is Call kind of request, it is reported to metrics
+	kind    string
+	noCache bool
+	// pool and priority choose Limiter queue
+	pool     string
+	priority int
 }
 
 // Connector interface, all query funcs take this interface, so you can replace it by connections from other libs
@@ -42,6 +63,25 @@ func (q Query) AddParam(name string, value string) {
 	q.params.Add(name, value)
 }
 
+// SetLocation overrides connection time zone for reading Date and DateTime values of this query,
+// useful for columns with explicit time zone like DateTime('Asia/Tokyo')
+func (q *Query) SetLocation(loc *time.Location) {
+	q.location = loc
+}
+
+// SetContext sets context of http request, request is aborted when context is done
+func (q *Query) SetContext(ctx context.Context) {
+	q.ctx = ctx
+}
+
+// Context returns query context, it is background context unless SetContext was called
+func (q Query) Context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+	return q.ctx
+}
+
 func (q Query) MergeParams(params url.Values) {
 	for key, value := range params {
 		if q.params.Get(key) == "" {
@@ -50,6 +90,20 @@ func (q Query) MergeParams(params url.Values) {
 	}
 }
 
+// withDefaultParam returns query copy with extra param unless it is already set, original params stay untouched
+func (q Query) withDefaultParam(name, value string) Query {
+	if q.params.Get(name) != "" {
+		return q
+	}
+	params := make(url.Values, len(q.params)+1)
+	for k, v := range q.params {
+		params[k] = v
+	}
+	params.Set(name, value)
+	q.params = params
+	return q
+}
+
 // Iterate over records. Note that it isnt real DB iterator while Clickhouse dont support them. All responce is stored in memory. Iterator just return them step by step.
 func (q Query) Iter(conn Connector) *Iter {
 	if conn == nil {
@@ -65,7 +119,49 @@ func (q Query) Iter(conn Connector) *Iter {
 		return &Iter{err: err}
 	}
 
-	return &Iter{text: resp}
+	return &Iter{text: resp, dec: decoder{loc: q.queryLocation(conn)}}
+}
+
+// queryLocation returns time zone for reading query results
+func (q Query) queryLocation(conn Connector) *time.Location {
+	if q.location != nil {
+		return q.location
+	}
+	if c, ok := conn.(interface{ Location() *time.Location }); ok {
+		return c.Location()
+	}
+	return time.UTC
+}
+
+// streamQuery returns response body of query, Connectors without Stream method read whole response first
+func streamQuery(conn Connector, q Query) (io.ReadCloser, error) {
+	if s, ok := conn.(interface {
+		Stream(q Query, readOnly bool) (io.ReadCloser, error)
+	}); ok {
+		return s.Stream(q, false)
+	}
+	return execReader(conn, q, false)
+}
+
+// Stream returns raw response body while it is received, statement should choose output format:
+//
+//	body, err := clickhouse.NewQuery("SELECT * FROM clicks FORMAT Parquet").Stream(conn)
+func (q Query) Stream(conn Connector) (io.ReadCloser, error) {
+	if conn == nil {
+		return nil, errors.New("Connection pointer is nil")
+	}
+	return streamQuery(conn, q)
+}
+
+func execReader(conn Connector, q Query, readOnly bool) (io.ReadCloser, error) {
+	resp, err := conn.Exec(q, readOnly)
+	if err == nil {
+		err = errorFromResponse(resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(resp)), nil
 }
 
 func (r *Iter) Len() int {
@@ -91,6 +187,8 @@ func (q Query) ExecScan(conn Connector, obj interface{}) error {
 	}
 
 	q.Stmt += " FORMAT JSON"
+	// named tuples are decoded into structs only as JSON objects
+	q = q.withDefaultParam("output_format_json_named_tuples_as_objects", "1")
 
 	resp, err := conn.Exec(q, false)
 
@@ -121,6 +219,7 @@ func (q Query) ExecScan(conn Connector, obj interface{}) error {
 type Iter struct {
 	err  error
 	text string
+	dec  decoder
 }
 
 func (r *Iter) Error() error {
@@ -137,7 +236,7 @@ func (r *Iter) Scan(vars ...interface{}) bool {
 		return false
 	}
 	for i, v := range vars {
-		err := unmarshal(v, a[i])
+		err := r.dec.unmarshal(v, a[i])
 		if err != nil {
 			r.err = err
 			return false
@@ -146,6 +245,23 @@ func (r *Iter) Scan(vars ...interface{}) bool {
 	return true
 }
 
+// ScanStruct reads row into exported fields of struct pointed by dst, TabSeparated has no column names,
+// so columns are matched with fields by order
+func (r *Iter) ScanStruct(dst interface{}) bool {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		r.err = fmt.Errorf("ScanStruct expects pointer to struct, got %T", dst)
+		return false
+	}
+	v = v.Elem()
+	fields := structFields(v.Type())
+	vars := make([]interface{}, len(fields))
+	for i, f := range fields {
+		vars[i] = v.Field(f).Addr().Interface()
+	}
+	return r.Scan(vars...)
+}
+
 func (r *Iter) fetchNext() string {
 	var res string
 	pos := strings.Index(r.text, "\n")
@@ -1,8 +1,13 @@
 package clickhouse
 
 import (
+	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -185,3 +190,90 @@ func getMockTransport(resp string) mockTransport {
 func getHost() string {
 	return "host.local"
 }
+
+func TestIter_ScanEscaped(t *testing.T) {
+	tr := getMockTransport("a\\tb\tline1\\nline2\nquote\\'\tback\\\\slash\n")
+	conn := NewConn(getHost(), tr)
+
+	iter := NewQuery("SELECT s1, s2 FROM t").Iter(conn)
+	var v1, v2 string
+	assert.True(t, iter.Scan(&v1, &v2))
+	assert.Equal(t, "a\tb", v1)
+	assert.Equal(t, "line1\nline2", v2)
+
+	assert.True(t, iter.Scan(&v1, &v2))
+	assert.Equal(t, "quote'", v1)
+	assert.Equal(t, `back\slash`, v2)
+
+	assert.False(t, iter.Scan(&v1, &v2))
+	assert.NoError(t, iter.Error())
+}
+
+type paramsTransport struct {
+	response string
+	params   url.Values
+}
+
+func (m *paramsTransport) Exec(host, params string, q Query, readOnly bool) (r string, err error) {
+	m.params = q.params
+	return m.response, nil
+}
+
+func TestQuery_ExecScanNested(t *testing.T) {
+	tr := &paramsTransport{response: `{"data":[{"id":1,"attrs":{"a":1,"b":2},"point":{"x":1.5,"y":-2},"tags":[["t1",1],["t2",2]]}]}`}
+	conn := NewConn(getHost(), tr)
+
+	type point struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	}
+	var res []struct {
+		ID    int              `json:"id"`
+		Attrs map[string]int64 `json:"attrs"`
+		Point point            `json:"point"`
+		Tags  []Tuple          `json:"tags"`
+	}
+
+	q := NewQuery("SELECT id, attrs, point, tags FROM t")
+	assert.NoError(t, q.ExecScan(conn, &res))
+	assert.Equal(t, "1", tr.params.Get("output_format_json_named_tuples_as_objects"))
+	assert.Equal(t, "", q.params.Get("output_format_json_named_tuples_as_objects"))
+
+	if assert.Len(t, res, 1) {
+		assert.Equal(t, map[string]int64{"a": 1, "b": 2}, res[0].Attrs)
+		assert.Equal(t, point{1.5, -2}, res[0].Point)
+		assert.Equal(t, []Tuple{{"t1", float64(1)}, {"t2", float64(2)}}, res[0].Tags)
+	}
+}
+
+func TestQuery_Stream(t *testing.T) {
+	body, err := NewQuery("SELECT 1 FORMAT CSV").Stream(NewConn(getHost(), getMockTransport("1\n")))
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	assert.Equal(t, "1\n", string(data))
+	assert.NoError(t, body.Close())
+
+	_, err = NewQuery("SELECT 1").Stream(NewConn(getHost(), getMockTransport("Code: 62, e.displayText() = DB::Exception: Syntax error")))
+	assert.Error(t, err)
+	_, err = NewQuery("SELECT 1").Stream(nil)
+	assert.Error(t, err)
+}
+
+func TestQuery_Context(t *testing.T) {
+	q := NewQuery("SELECT 1")
+	assert.Equal(t, context.Background(), q.Context())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.SetContext(ctx)
+	assert.Equal(t, ctx, q.Context())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "1")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+	assert.ErrorIs(t, q.Exec(conn), context.Canceled)
+	_, err := conn.Exec(q, true)
+	assert.ErrorIs(t, err, context.Canceled)
+}
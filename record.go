@@ -0,0 +1,216 @@
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// Recording is one Exec call saved by RecordTransport, password param is not saved
+type Recording struct {
+	Host      string     `json:"host"`
+	Params    string     `json:"params,omitempty"`
+	Query     string     `json:"query"`
+	ReadOnly  bool       `json:"read_only,omitempty"`
+	Externals []External `json:"externals,omitempty"`
+	Body      goldenData `json:"body,omitempty"`
+	Response  goldenData `json:"response"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// goldenData is saved as JSON string if it is valid UTF-8, binary data is saved as {"base64": "..."}
+type goldenData []byte
+
+func (d goldenData) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(d) {
+		return json.Marshal(string(d))
+	}
+	return json.Marshal(struct {
+		Base64 []byte `json:"base64"`
+	}{d})
+}
+
+func (d *goldenData) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*d = goldenData(s)
+		return nil
+	}
+	var b struct {
+		Base64 []byte `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	*d = b.Base64
+	return nil
+}
+
+// newRecording describes request, data of request body is read and replaced in q
+func newRecording(host, params string, q *Query, readOnly bool) (Recording, error) {
+	stmt, err := prepareQuery(*q)
+	if err != nil {
+		return Recording{}, err
+	}
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return Recording{}, err
+	}
+	for name, v := range q.params {
+		values[name] = v
+	}
+	values.Del("password")
+
+	rec := Recording{Host: host, Params: values.Encode(), Query: stmt, ReadOnly: readOnly, Externals: q.externals}
+	if q.body != nil {
+		body, err := io.ReadAll(q.body)
+		if err != nil {
+			return Recording{}, err
+		}
+		rec.Body = body
+		q.body = bytes.NewReader(body)
+	}
+	return rec, nil
+}
+
+// matches compares request with saved one
+func (r Recording) matches(req Recording) bool {
+	if r.Host != req.Host || r.Params != req.Params || r.Query != req.Query || r.ReadOnly != req.ReadOnly ||
+		!bytes.Equal(r.Body, req.Body) || len(r.Externals) != len(req.Externals) {
+		return false
+	}
+	for i, ext := range r.Externals {
+		other := req.Externals[i]
+		if ext.Name != other.Name || ext.Structure != other.Structure || !bytes.Equal(ext.Data, other.Data) {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordTransport passes requests to other transport and records them for ReplayTransport:
+//
+//	var record = flag.Bool("record", false, "record queries to staging server")
+//
+//	if *record {
+//		tr := clickhouse.NewRecordTransport(clickhouse.NewHttpTransport())
+//		defer tr.Save("testdata/report.json")
+//		conn = clickhouse.NewConn(stagingHost, tr)
+//	} else {
+//		tr, err := clickhouse.LoadReplayTransport("testdata/report.json")
+//		conn = clickhouse.NewConn(stagingHost, tr)
+//	}
+type RecordTransport struct {
+	transport Transport
+	mx        sync.Mutex
+	records   []Recording
+}
+
+func NewRecordTransport(t Transport) *RecordTransport {
+	return &RecordTransport{transport: t}
+}
+
+// Exec makes request with wrapped transport and records it
+func (t *RecordTransport) Exec(host, params string, q Query, readOnly bool) (string, error) {
+	rec, err := newRecording(host, params, &q, readOnly)
+	if err != nil {
+		return "", err
+	}
+	res, err := t.transport.Exec(host, params, q, readOnly)
+	rec.Response = goldenData(res)
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	t.mx.Lock()
+	t.records = append(t.records, rec)
+	t.mx.Unlock()
+	return res, err
+}
+
+// Recordings returns recorded requests
+func (t *RecordTransport) Recordings() []Recording {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]Recording(nil), t.records...)
+}
+
+// Save writes recorded requests into golden file
+func (t *RecordTransport) Save(path string) error {
+	data, err := json.MarshalIndent(t.Recordings(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReplayTransport returns responses of recorded requests without server, each recording is used once
+// in order of recording. Requests without recording fail
+type ReplayTransport struct {
+	mx      sync.Mutex
+	records []Recording
+	used    []bool
+}
+
+// NewReplayTransport creates transport replaying records
+func NewReplayTransport(records []Recording) *ReplayTransport {
+	return &ReplayTransport{records: records, used: make([]bool, len(records))}
+}
+
+// LoadReplayTransport reads golden file saved by RecordTransport
+func LoadReplayTransport(path string) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []Recording
+	if err = json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("Cannot read recordings from %s: %v", path, err)
+	}
+	return NewReplayTransport(records), nil
+}
+
+// Exec returns recorded response of first unused matching recording
+func (t *ReplayTransport) Exec(host, params string, q Query, readOnly bool) (string, error) {
+	req, err := newRecording(host, params, &q, readOnly)
+	if err != nil {
+		return "", err
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for i, rec := range t.records {
+		if t.used[i] || !rec.matches(req) {
+			continue
+		}
+		t.used[i] = true
+		if rec.Error != "" {
+			// server exceptions are *DbError like in real requests
+			if err := errorFromResponse(string(rec.Response)); err != nil {
+				return string(rec.Response), err
+			}
+			return string(rec.Response), errors.New(rec.Error)
+		}
+		return string(rec.Response), nil
+	}
+	return "", fmt.Errorf("No recorded response for query %s with params %s", req.Query, req.Params)
+}
+
+// Unused returns recordings which were not requested, e.g. to check that test made all recorded queries
+func (t *ReplayTransport) Unused() []Recording {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	var res []Recording
+	for i, rec := range t.records {
+		if !t.used[i] {
+			res = append(res, rec)
+		}
+	}
+	return res
+}
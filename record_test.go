@@ -0,0 +1,108 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplayTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case strings.Contains(string(body), "binary"):
+			w.Write([]byte{0xff, 0x00, 0x01})
+		case r.URL.Query().Get("query") != "":
+			io.WriteString(w, "")
+		default:
+			io.WriteString(w, "1\t"+r.URL.Query().Get("max_threads")+"\n")
+		}
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "golden.json")
+
+	rec := NewRecordTransport(NewHttpTransport())
+	conn := NewAuthConn(server.URL, rec, "user", "secret")
+	q := NewQuery("SELECT :value:, 'x'", 1)
+	q.params.Set("max_threads", "2")
+	var a, b int
+	iter := q.Iter(conn)
+	assert.True(t, iter.Scan(&a, &b))
+	body, err := NewQuery("SELECT binary").Stream(conn)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(body)
+	assert.Equal(t, []byte{0xff, 0x00, 0x01}, data)
+	assert.NoError(t, conn.InsertFrom(context.Background(), "t", "CSV", strings.NewReader("1,2\n")))
+	assert.NoError(t, rec.Save(path))
+
+	golden, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(golden), "secret")
+	assert.Contains(t, string(golden), `"query": "SELECT 1, 'x'"`)
+	assert.Contains(t, string(golden), `"base64"`)
+
+	replay, err := LoadReplayTransport(path)
+	assert.NoError(t, err)
+	conn = NewAuthConn(server.URL, replay, "user", "other password")
+	server.Close()
+
+	iter = q.Iter(conn)
+	assert.True(t, iter.Scan(&a, &b))
+	assert.Equal(t, 1, a)
+	assert.Equal(t, 2, b)
+	body, err = NewQuery("SELECT binary").Stream(conn)
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(body)
+	assert.Equal(t, []byte{0xff, 0x00, 0x01}, data)
+	assert.Len(t, replay.Unused(), 1)
+	assert.Error(t, conn.InsertFrom(context.Background(), "t", "CSV", strings.NewReader("1,3\n")))
+	assert.NoError(t, conn.InsertFrom(context.Background(), "t", "CSV", strings.NewReader("1,2\n")))
+	assert.Empty(t, replay.Unused())
+
+	// each recording is used once
+	err = q.Exec(conn)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "No recorded response for query SELECT 1, 'x'")
+	}
+}
+
+func TestReplayTransport_Error(t *testing.T) {
+	rec := NewRecordTransport(badTransport{err: errors.New("Connection timeout")})
+	assert.Error(t, NewConn(getHost(), rec).Ping())
+
+	replay := NewReplayTransport(rec.Recordings())
+	assert.EqualError(t, NewConn(getHost(), replay).Ping(), "Connection timeout")
+
+	_, err := LoadReplayTransport(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	// server exception keeps its code after replay
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist")
+	}))
+	defer server.Close()
+	rec = NewRecordTransport(NewHttpTransport())
+	q := NewQuery("SELECT * FROM missing")
+	err = q.Exec(NewConn(server.URL, rec))
+	if assert.IsType(t, &DbError{}, err) {
+		assert.Equal(t, 60, err.(*DbError).Code())
+	}
+	path := filepath.Join(t.TempDir(), "golden.json")
+	assert.NoError(t, rec.Save(path))
+	replay, err = LoadReplayTransport(path)
+	assert.NoError(t, err)
+	err = q.Exec(NewConn(server.URL, replay))
+	if assert.IsType(t, &DbError{}, err) {
+		assert.Equal(t, 60, err.(*DbError).Code())
+		assert.Equal(t, "DB::Exception: Table default.missing doesn't exist", err.(*DbError).Message())
+	}
+}
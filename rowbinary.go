@@ -0,0 +1,550 @@
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatRowBinary                  = "RowBinary"
+	FormatRowBinaryWithNamesAndTypes = "RowBinaryWithNamesAndTypes"
+)
+
+// RowBinaryEncoder writes rows in RowBinary format, values are converted according to schema types
+type RowBinaryEncoder struct {
+	w      io.Writer
+	schema Schema
+	types  []*ColumnType
+	buf    []byte
+}
+
+// NewRowBinaryEncoder creates encoder for schema, it fails on unsupported column types
+func NewRowBinaryEncoder(w io.Writer, schema Schema) (*RowBinaryEncoder, error) {
+	if len(schema) == 0 {
+		return nil, errors.New("Schema is empty")
+	}
+	types := make([]*ColumnType, len(schema))
+	for i, col := range schema {
+		t, err := parseColumnType(col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		types[i] = t
+	}
+	return &RowBinaryEncoder{w: w, schema: schema, types: types}, nil
+}
+
+// WriteHeader writes column names and types, it is required once before rows for RowBinaryWithNamesAndTypes
+func (e *RowBinaryEncoder) WriteHeader() error {
+	buf := binary.AppendUvarint(e.buf[:0], uint64(len(e.schema)))
+	for _, col := range e.schema {
+		buf = appendString(buf, col.Name)
+	}
+	for _, col := range e.schema {
+		buf = appendString(buf, col.Type)
+	}
+	e.buf = buf
+	_, err := e.w.Write(buf)
+	return err
+}
+
+// Encode writes one row, nothing is written if any value cannot be converted
+func (e *RowBinaryEncoder) Encode(row Row) error {
+	if len(row) != len(e.types) {
+		return fmt.Errorf("Row has %d values, schema has %d columns", len(row), len(e.types))
+	}
+	buf := e.buf[:0]
+	var err error
+	for i, t := range e.types {
+		if buf, err = appendBinary(buf, t, row[i]); err != nil {
+			return fmt.Errorf("column %s: %v", e.schema[i].Name, err)
+		}
+	}
+	e.buf = buf
+	_, err = e.w.Write(buf)
+	return err
+}
+
+// RowBinaryInsert streams rows into INSERT ... FORMAT RowBinary request, rows are sent while they are written:
+//
+//	schema, _ := clickhouse.DescribeTable(conn, "clicks")
+//	ins, _ := clickhouse.NewRowBinaryInsert(conn, "clicks", clickhouse.FormatRowBinary, schema)
+//	for _, c := range clicks {
+//		if err := ins.Write(clickhouse.Row{c.Time, c.URL, c.Tags}); err != nil {
+//			ins.Abort(err) // rows written before are not committed
+//			return err
+//		}
+//	}
+//	err := ins.Close()
+type RowBinaryInsert struct {
+	enc    *RowBinaryEncoder
+	stream *insertStream
+}
+
+// NewRowBinaryInsert starts insert request, format is FormatRowBinary or FormatRowBinaryWithNamesAndTypes
+func NewRowBinaryInsert(conn Connector, table, format string, schema Schema) (*RowBinaryInsert, error) {
+	if conn == nil {
+		return nil, errors.New("Connection pointer is nil")
+	}
+	if format != FormatRowBinary && format != FormatRowBinaryWithNamesAndTypes {
+		return nil, fmt.Errorf("Format %s is not RowBinary", format)
+	}
+
+	stream := &insertStream{}
+	enc, err := NewRowBinaryEncoder(stream, schema)
+	if err != nil {
+		return nil, err
+	}
+	stream.start(conn, NewQuery(fmt.Sprintf("INSERT INTO %s (%s) FORMAT %s", table, strings.Join(schema.Names(), ","), format)))
+	if format == FormatRowBinaryWithNamesAndTypes {
+		if err = enc.WriteHeader(); err != nil {
+			stream.Abort(err)
+			return nil, err
+		}
+	}
+	return &RowBinaryInsert{enc: enc, stream: stream}, nil
+}
+
+// Write encodes and sends one row, it returns request error if insert has already failed
+func (i *RowBinaryInsert) Write(row Row) error {
+	return i.enc.Encode(row)
+}
+
+// Close finishes request body and waits for server response
+func (i *RowBinaryInsert) Close() error {
+	return i.stream.Close()
+}
+
+// Abort breaks request, so server does not commit rows which were already sent
+func (i *RowBinaryInsert) Abort(err error) {
+	i.stream.Abort(err)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// binaryValue unwraps driver.Valuer and pointers, NULL is nil, nil pointer or nil from driver.Valuer
+func binaryValue(v interface{}) (interface{}, bool, error) {
+	v = derefValue(v)
+	if valuer, ok := v.(driver.Valuer); ok {
+		val, err := valuer.Value()
+		if err != nil {
+			return nil, false, err
+		}
+		v = val
+	}
+	if v == nil {
+		return nil, true, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, true, nil
+		}
+		if _, ok := v.(*big.Int); !ok {
+			return binaryValue(rv.Elem().Interface())
+		}
+	}
+	return v, false, nil
+}
+
+// appendBinary encodes value as RowBinary value of type t
+func appendBinary(buf []byte, t *ColumnType, value interface{}) ([]byte, error) {
+	// dictionary encoding is used by Native format only, so LowCardinality(Nullable(T)) is Nullable(T)
+	for t.Name == "LowCardinality" {
+		t = t.Args[0]
+	}
+	value, null, err := binaryValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if t.Name == "Nullable" {
+		if null {
+			return append(buf, 1), nil
+		}
+		return appendBinary(append(buf, 0), t.Args[0], value)
+	}
+	if null {
+		return nil, fmt.Errorf("NULL is not allowed for %s", t)
+	}
+
+	switch t.Name {
+	case "Int8":
+		return appendInt(buf, t, value, 8, true)
+	case "Int16":
+		return appendInt(buf, t, value, 16, true)
+	case "Int32":
+		return appendInt(buf, t, value, 32, true)
+	case "Int64":
+		return appendInt(buf, t, value, 64, true)
+	case "Int128":
+		return appendInt(buf, t, value, 128, true)
+	case "Int256":
+		return appendInt(buf, t, value, 256, true)
+	case "UInt8":
+		if b, ok := value.(bool); ok {
+			return appendBool(buf, b), nil
+		}
+		return appendInt(buf, t, value, 8, false)
+	case "UInt16":
+		return appendInt(buf, t, value, 16, false)
+	case "UInt32":
+		return appendInt(buf, t, value, 32, false)
+	case "UInt64":
+		return appendInt(buf, t, value, 64, false)
+	case "UInt128":
+		return appendInt(buf, t, value, 128, false)
+	case "UInt256":
+		return appendInt(buf, t, value, 256, false)
+	case "Float32", "Float64":
+		var f float64
+		switch v := reflect.ValueOf(value); v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f = v.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(v.Uint())
+		default:
+			return nil, unsupportedBinary(t, value)
+		}
+		if t.Name == "Float32" {
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case "Bool":
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Bool {
+			return appendBool(buf, v.Bool()), nil
+		}
+		return nil, unsupportedBinary(t, value)
+	case "String":
+		s, ok := binaryString(value)
+		if !ok {
+			return nil, unsupportedBinary(t, value)
+		}
+		return appendString(buf, s), nil
+	case "FixedString":
+		s, ok := binaryString(value)
+		if !ok {
+			return nil, unsupportedBinary(t, value)
+		}
+		if len(s) > t.Size {
+			return nil, fmt.Errorf("Value of %d bytes is too long for %s", len(s), t)
+		}
+		buf = append(buf, s...)
+		return append(buf, make([]byte, t.Size-len(s))...), nil
+	case "Decimal":
+		return appendDecimal(buf, t, value)
+	case "Date", "Date32":
+		tm, ok := binaryTime(value)
+		if !ok {
+			return appendInt(buf, t, value, map[string]int{"Date": 16, "Date32": 32}[t.Name], t.Name == "Date32")
+		}
+		days := time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+		if t.Name == "Date" {
+			return appendInt(buf, t, days, 16, false)
+		}
+		return appendInt(buf, t, days, 32, true)
+	case "DateTime":
+		tm, ok := binaryTime(value)
+		if !ok {
+			return appendInt(buf, t, value, 32, false)
+		}
+		return appendInt(buf, t, tm.Unix(), 32, false)
+	case "DateTime64":
+		tm, ok := binaryTime(value)
+		if !ok {
+			return appendInt(buf, t, value, 64, true)
+		}
+		scale := int64(math.Pow10(t.Precision))
+		ticks := tm.Unix()*scale + int64(tm.Nanosecond())/int64(math.Pow10(9-t.Precision))
+		return binary.LittleEndian.AppendUint64(buf, uint64(ticks)), nil
+	case "UUID":
+		var u UUID
+		switch v := value.(type) {
+		case UUID:
+			u = v
+		case [16]byte:
+			u = v
+		case string:
+			if u, err = ParseUUID(v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, unsupportedBinary(t, value)
+		}
+		// UUID is stored as two little endian UInt64 halves
+		for i := 7; i >= 0; i-- {
+			buf = append(buf, u[i])
+		}
+		for i := 15; i >= 8; i-- {
+			buf = append(buf, u[i])
+		}
+		return buf, nil
+	case "IPv4", "IPv6":
+		ip, err := binaryIP(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v for %s", err, t)
+		}
+		if t.Name == "IPv6" {
+			return append(buf, ip.To16()...), nil
+		}
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("Address %s is not IPv4", ip)
+		}
+		return binary.LittleEndian.AppendUint32(buf, binary.BigEndian.Uint32(ip4)), nil
+	case "Enum8", "Enum16":
+		bits := map[string]int{"Enum8": 8, "Enum16": 16}[t.Name]
+		if name, ok := binaryString(value); ok {
+			code, ok := t.Enum.Value(name)
+			if !ok {
+				return nil, fmt.Errorf("Unknown value %q of %s", name, t)
+			}
+			return appendInt(buf, t, code, bits, true)
+		}
+		return appendInt(buf, t, value, bits, true)
+	case "Array":
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, unsupportedBinary(t, value)
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if buf, err = appendBinary(buf, t.Args[0], v.Index(i).Interface()); err != nil {
+				return nil, fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		return buf, nil
+	case "Nested":
+		// flatten_nested=0 keeps Nested as array of tuples
+		return appendBinary(buf, &ColumnType{Name: "Array", Args: []*ColumnType{{Name: "Tuple", Args: t.Args, raw: t.raw}}, raw: t.raw}, value)
+	case "Tuple":
+		items, err := binaryTuple(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != len(t.Args) {
+			return nil, fmt.Errorf("Tuple of %d elements cannot be written as %s", len(items), t)
+		}
+		for i, item := range items {
+			if buf, err = appendBinary(buf, t.Args[i], item); err != nil {
+				return nil, fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		return buf, nil
+	case "Map":
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Map {
+			return nil, unsupportedBinary(t, value)
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			if buf, err = appendBinary(buf, t.Args[0], iter.Key().Interface()); err != nil {
+				return nil, fmt.Errorf("key %v: %v", iter.Key(), err)
+			}
+			if buf, err = appendBinary(buf, t.Args[1], iter.Value().Interface()); err != nil {
+				return nil, fmt.Errorf("value of %v: %v", iter.Key(), err)
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("Type %s is not supported by RowBinary", t)
+}
+
+func unsupportedBinary(t *ColumnType, value interface{}) error {
+	return fmt.Errorf("Type %T cannot be written as %s", value, t)
+}
+
+func appendBool(buf []byte, b bool) []byte {
+	if b {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+// appendInt writes integer of any Go type as little endian value of bits size, values out of range are rejected
+func appendInt(buf []byte, t *ColumnType, value interface{}, bits int, signed bool) ([]byte, error) {
+	var b *big.Int
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if bits > 64 {
+			b = big.NewInt(i)
+			break
+		}
+		if signed && bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) ||
+			!signed && (i < 0 || bits < 64 && i >= 1<<bits) {
+			return nil, fmt.Errorf("Value %d overflows %s", i, t)
+		}
+		return appendUint(buf, uint64(i), bits), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if bits > 64 {
+			b = new(big.Int).SetUint64(u)
+			break
+		}
+		if signed && u >= 1<<(bits-1) || !signed && bits < 64 && u >= 1<<bits {
+			return nil, fmt.Errorf("Value %d overflows %s", u, t)
+		}
+		return appendUint(buf, u, bits), nil
+	default:
+		if i, ok := value.(*big.Int); ok {
+			b = i
+			break
+		}
+		return nil, unsupportedBinary(t, value)
+	}
+	return appendBigInt(buf, t, b, bits, signed)
+}
+
+func appendUint(buf []byte, u uint64, bits int) []byte {
+	for i := 0; i < bits/8; i++ {
+		buf = append(buf, byte(u>>(8*i)))
+	}
+	return buf
+}
+
+// appendBigInt writes two's complement little endian representation of b
+func appendBigInt(buf []byte, t *ColumnType, b *big.Int, bits int, signed bool) ([]byte, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	min, max := new(big.Int), limit
+	if signed {
+		max = new(big.Int).Rsh(limit, 1)
+		min = new(big.Int).Neg(max)
+	}
+	if b.Cmp(min) < 0 || b.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("Value %s overflows %s", b, t)
+	}
+
+	v := b
+	if b.Sign() < 0 {
+		v = new(big.Int).Add(limit, b)
+	}
+	data := v.FillBytes(make([]byte, bits/8))
+	for i := len(data) - 1; i >= 0; i-- {
+		buf = append(buf, data[i])
+	}
+	return buf, nil
+}
+
+func appendDecimal(buf []byte, t *ColumnType, value interface{}) ([]byte, error) {
+	var (
+		d   Decimal
+		err error
+	)
+	switch v := value.(type) {
+	case Decimal:
+		d = v
+	case string:
+		d, err = ParseDecimal(v)
+	case float32:
+		// shortest text of float32 value, 64 bits would add digits of binary representation
+		d, err = ParseDecimal(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	case float64:
+		d, err = ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	case *big.Int:
+		d = Decimal{Value: v}
+	default:
+		switch rv := reflect.ValueOf(value); rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			d = NewDecimal(rv.Int(), 0)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			d = Decimal{Value: new(big.Int).SetUint64(rv.Uint())}
+		default:
+			return nil, unsupportedBinary(t, value)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d, err = d.Rescale(t.Scale); err != nil {
+		return nil, err
+	}
+
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Precision)), nil)
+	if new(big.Int).Abs(d.unscaled()).Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("Decimal %s overflows %s", d, t)
+	}
+	return appendBigInt(buf, t, d.unscaled(), decimalBits(t.Precision), true)
+}
+
+func binaryString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		return string(text), err == nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.String {
+		return v.String(), true
+	}
+	return "", false
+}
+
+func binaryTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case Date:
+		return time.Time(v), true
+	case Date32:
+		return time.Time(v), true
+	case DateTime:
+		return time.Time(v), true
+	case DateTime64:
+		return v.Time, true
+	}
+	return time.Time{}, false
+}
+
+func binaryIP(value interface{}) (net.IP, error) {
+	switch v := value.(type) {
+	case net.IP:
+		return v, nil
+	case netip.Addr:
+		return net.IP(v.Unmap().AsSlice()), nil
+	case string:
+		return parseIP(v)
+	}
+	return nil, fmt.Errorf("Type %T cannot be written", value)
+}
+
+// binaryTuple returns tuple elements of Tuple, Array, []interface{} and structs
+func binaryTuple(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case Tuple:
+		return v, nil
+	case Array:
+		return v, nil
+	case []interface{}:
+		return v, nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Type %T cannot be written as Tuple", value)
+	}
+	fields := structFields(v.Type())
+	res := make([]interface{}, len(fields))
+	for i, f := range fields {
+		res[i] = v.Field(f).Interface()
+	}
+	return res, nil
+}
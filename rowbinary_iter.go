@@ -0,0 +1,1004 @@
+package clickhouse
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	dateType       = reflect.TypeOf(Date{})
+	date32Type     = reflect.TypeOf(Date32{})
+	dateTimeType   = reflect.TypeOf(DateTime{})
+	dateTime64Type = reflect.TypeOf(DateTime64{})
+	decimalType    = reflect.TypeOf(Decimal{})
+	bigIntType     = reflect.TypeOf(big.Int{})
+	bigIntPtrType  = reflect.TypeOf(&big.Int{})
+	uuidType       = reflect.TypeOf(UUID{})
+	netIPType      = reflect.TypeOf(net.IP{})
+	netipAddrType  = reflect.TypeOf(netip.Addr{})
+	interfaceType  = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// BinaryIter reads RowBinaryWithNamesAndTypes result while it is received, values are decoded
+// from binary data directly into scan destinations:
+//
+//	iter := clickhouse.NewQuery("SELECT id, price FROM orders").BinaryIter(conn)
+//	defer iter.Close()
+//	for iter.Scan(&id, &price) {
+//	}
+//	err := iter.Error()
+type BinaryIter struct {
+	body   io.ReadCloser
+	r      binaryReader
+	schema Schema
+	types  []*ColumnType
+	fields map[reflect.Type][]int
+	err    error
+}
+
+// BinaryIter makes request in RowBinaryWithNamesAndTypes format, response is read on Scan calls
+func (q Query) BinaryIter(conn Connector) *BinaryIter {
+	if conn == nil {
+		return &BinaryIter{err: errors.New("Connection pointer is nil")}
+	}
+	q.Stmt += " FORMAT " + FormatRowBinaryWithNamesAndTypes
+
+	body, err := streamQuery(conn, q)
+	if err != nil {
+		return &BinaryIter{err: err}
+	}
+	return newBinaryIter(body, q.queryLocation(conn))
+}
+
+func newBinaryIter(body io.ReadCloser, loc *time.Location) *BinaryIter {
+	iter := &BinaryIter{
+		body:   body,
+		r:      newBinaryReader(body, loc),
+		fields: make(map[reflect.Type][]int),
+	}
+	if iter.err = iter.readHeader(); iter.err != nil {
+		iter.Close()
+	}
+	return iter
+}
+
+func (r *BinaryIter) readHeader() error {
+	n, err := r.r.readUvarint()
+	if err != nil {
+		return readError("Cannot read RowBinary header", err)
+	}
+	r.schema = make(Schema, n)
+	for i := range r.schema {
+		if r.schema[i].Name, err = r.r.readString(); err != nil {
+			return readError("Cannot read RowBinary header", err)
+		}
+	}
+	r.types = make([]*ColumnType, n)
+	for i := range r.schema {
+		if r.schema[i].Type, err = r.r.readString(); err != nil {
+			return readError("Cannot read RowBinary header", err)
+		}
+		if r.types[i], err = parseColumnType(r.schema[i].Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Columns returns names and types of result columns
+func (r *BinaryIter) Columns() Schema {
+	return r.schema
+}
+
+func (r *BinaryIter) Error() error {
+	return r.err
+}
+
+// Close releases response body, it is closed automatically when all rows are read
+func (r *BinaryIter) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// Scan reads next row, columns after len(vars) are skipped
+func (r *BinaryIter) Scan(vars ...interface{}) bool {
+	if r.err != nil {
+		return false
+	}
+	if len(vars) > len(r.types) {
+		r.setError(fmt.Errorf("Result has %d columns, %d values requested", len(r.types), len(vars)))
+		return false
+	}
+	return r.scan(vars, nil)
+}
+
+// ScanStruct reads next row into struct pointed by dst, columns are matched with fields by `ch` tag,
+// `json` tag or field name. Columns without field are skipped
+func (r *BinaryIter) ScanStruct(dst interface{}) bool {
+	if r.err != nil {
+		return false
+	}
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		r.setError(fmt.Errorf("ScanStruct expects pointer to struct, got %T", dst))
+		return false
+	}
+	v = v.Elem()
+
+	fields, ok := r.fields[v.Type()]
+	if !ok {
+		fields = r.structColumns(v.Type())
+		r.fields[v.Type()] = fields
+	}
+	values := make([]reflect.Value, len(r.types))
+	for i, f := range fields {
+		if f >= 0 {
+			values[i] = v.Field(f)
+		}
+	}
+	return r.scan(nil, values)
+}
+
+// structColumns returns field index for each column, -1 if column has no field
+func (r *BinaryIter) structColumns(t reflect.Type) []int {
+	res := make([]int, len(r.schema))
+	for i, col := range r.schema {
+		res[i] = -1
+		for _, f := range structFields(t) {
+			field := t.Field(f)
+			name := fieldColumn(field)
+			if name == col.Name || name == "" && strings.EqualFold(field.Name, col.Name) {
+				res[i] = f
+				break
+			}
+		}
+	}
+	return res
+}
+
+// fieldColumn returns column name from `ch` or `json` tag, empty name means field is matched by its name
+func fieldColumn(field reflect.StructField) string {
+	if name := field.Tag.Get("ch"); name != "" {
+		return name
+	}
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// scan reads row into scan pointers or settable values, columns without destination are skipped
+func (r *BinaryIter) scan(ptrs []interface{}, values []reflect.Value) bool {
+	if r.err != nil || r.body == nil {
+		return false
+	}
+	if _, err := r.r.r.Peek(1); err != nil {
+		if err != io.EOF {
+			r.setError(err)
+		}
+		r.Close()
+		return false
+	}
+	for i, t := range r.types {
+		var v reflect.Value
+		switch {
+		case i < len(ptrs):
+			ok, err := r.r.decodeFast(t, ptrs[i])
+			if err != nil {
+				r.setError(readError("column "+r.schema[i].Name, err))
+				return false
+			}
+			if ok {
+				continue
+			}
+			rv := reflect.ValueOf(ptrs[i])
+			if rv.Kind() != reflect.Ptr || rv.IsNil() {
+				r.setError(fmt.Errorf("Scan destination %d is not a pointer: %T", i, ptrs[i]))
+				return false
+			}
+			v = rv.Elem()
+		case i < len(values) && values[i].IsValid():
+			v = values[i]
+		default:
+			// value is read anyway to move to next column
+			v = reflect.New(interfaceType).Elem()
+		}
+		if err := r.r.decode(t, v); err != nil {
+			r.setError(readError("column "+r.schema[i].Name, err))
+			return false
+		}
+	}
+	return true
+}
+
+func (r *BinaryIter) setError(err error) {
+	r.err = err
+	r.Close()
+}
+
+// binaryReader decodes RowBinary values
+type binaryReader struct {
+	r    *bufio.Reader
+	tail *tailReader
+	loc  *time.Location
+}
+
+func newBinaryReader(body io.Reader, loc *time.Location) binaryReader {
+	tail := &tailReader{r: body}
+	return binaryReader{r: bufio.NewReaderSize(tail, 64*1024), tail: tail, loc: loc}
+}
+
+// readError adds context to decoding error, server exceptions are returned as is
+func readError(context string, err error) error {
+	var dbErr *DbError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+	return fmt.Errorf("%s: %v", context, err)
+}
+
+// streamTailSize is amount of last received bytes searched for exception
+const streamTailSize = 16 * 1024
+
+// tailReader keeps end of received data, server writes exception there when query fails after sending some rows
+type tailReader struct {
+	r    io.Reader
+	tail []byte
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	data := p[:n]
+	if len(data) > streamTailSize {
+		data = data[len(data)-streamTailSize:]
+	}
+	if len(t.tail)+len(data) > 2*streamTailSize {
+		keep := streamTailSize - len(data)
+		if keep < 0 {
+			keep = 0
+		}
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-keep:]...)
+	}
+	t.tail = append(t.tail, data...)
+	return n, err
+}
+
+// streamError returns server exception if stream ended in the middle of value, other errors are kept
+func (r *binaryReader) streamError(err error) error {
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if r.tail != nil {
+		if res := exceptionFromTail(r.tail.tail); res != nil {
+			return res
+		}
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// read returns next n bytes, result is valid until next read
+func (r *binaryReader) read(n int) ([]byte, error) {
+	if n <= r.r.Size() {
+		b, err := r.r.Peek(n)
+		if err != nil {
+			return nil, r.streamError(err)
+		}
+		r.r.Discard(n)
+		return b, nil
+	}
+	// length may come from broken data, so buffer grows only while data is available
+	b, err := io.ReadAll(io.LimitReader(r.r, int64(n)))
+	if err == nil && len(b) < n {
+		err = r.streamError(io.ErrUnexpectedEOF)
+	}
+	return b, err
+}
+
+func (r *binaryReader) readUvarint() (uint64, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		err = r.streamError(err)
+	}
+	return n, err
+}
+
+func (r *binaryReader) readBytes() ([]byte, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("String length %d is too big", n)
+	}
+	return r.read(int(n))
+}
+
+func (r *binaryReader) readString() (string, error) {
+	b, err := r.readBytes()
+	return string(b), err
+}
+
+func (r *binaryReader) readUint(bits int) (uint64, error) {
+	b, err := r.read(bits / 8)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		u = u<<8 | uint64(b[i])
+	}
+	return u, nil
+}
+
+func (r *binaryReader) readInt(bits int) (int64, error) {
+	u, err := r.readUint(bits)
+	// sign extension
+	shift := 64 - bits
+	return int64(u<<shift) >> shift, err
+}
+
+func (r *binaryReader) readBig(bits int, signed bool) (*big.Int, error) {
+	b, err := r.read(bits / 8)
+	if err != nil {
+		return nil, err
+	}
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	v := new(big.Int).SetBytes(be)
+	if signed && be[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+	}
+	return v, nil
+}
+
+func (r *binaryReader) location(t *ColumnType) *time.Location {
+	if t.Location != nil {
+		return t.Location
+	}
+	if r.loc != nil {
+		return r.loc
+	}
+	return time.UTC
+}
+
+// decodeFast reads most common types into matching pointers without reflection, it returns false for other cases
+func (r *binaryReader) decodeFast(t *ColumnType, dst interface{}) (bool, error) {
+	var err error
+	switch d := dst.(type) {
+	case *string:
+		if t.Name == "String" {
+			var b []byte
+			b, err = r.readBytes()
+			*d = string(b)
+			return true, err
+		}
+	case *int64:
+		switch t.Name {
+		case "Int8", "Int16", "Int32", "Int64":
+			*d, err = r.readInt(typeBits(t.Name))
+			return true, err
+		}
+	case *int:
+		switch t.Name {
+		case "Int8", "Int16", "Int32":
+			var i int64
+			i, err = r.readInt(typeBits(t.Name))
+			*d = int(i)
+			return true, err
+		}
+	case *uint64:
+		switch t.Name {
+		case "UInt8", "UInt16", "UInt32", "UInt64":
+			*d, err = r.readUint(typeBits(t.Name))
+			return true, err
+		}
+	case *float64:
+		if t.Name == "Float64" {
+			var u uint64
+			u, err = r.readUint(64)
+			*d = math.Float64frombits(u)
+			return true, err
+		}
+	case *bool:
+		if t.Name == "Bool" {
+			var u uint64
+			u, err = r.readUint(8)
+			*d = u != 0
+			return true, err
+		}
+	case *time.Time:
+		switch t.Name {
+		case "Date", "Date32", "DateTime", "DateTime64":
+			*d, err = r.readTime(t)
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// decode reads value of type t into settable v
+func (r *binaryReader) decode(t *ColumnType, v reflect.Value) error {
+	switch t.Name {
+	case "Nullable":
+		flag, err := r.read(1)
+		if err != nil {
+			return err
+		}
+		if flag[0] == 0 {
+			return r.decode(t.Args[0], v)
+		}
+		if v.CanAddr() {
+			switch u := v.Addr().Interface().(type) {
+			case sql.Scanner:
+				return u.Scan(nil)
+			case ClickHouseUnmarshaler:
+				return u.UnmarshalClickHouse(`\N`)
+			}
+		}
+		return setNull(v)
+	case "LowCardinality":
+		return r.decode(t.Args[0], v)
+	}
+
+	if v.Kind() == reflect.Ptr && v.Type() != bigIntPtrType {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return r.decode(t, v.Elem())
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		val, err := r.readValue(t)
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			v.Set(reflect.ValueOf(val))
+		}
+		return nil
+	}
+	if v.CanAddr() && !isBinaryType(v.Type()) {
+		switch u := v.Addr().Interface().(type) {
+		case ClickHouseUnmarshaler, sql.Scanner, encoding.TextUnmarshaler:
+			val, err := r.readValue(t)
+			if err != nil {
+				return err
+			}
+			return unmarshalBinaryValue(u, val)
+		}
+	}
+
+	switch t.Name {
+	case "Int8", "Int16", "Int32", "Int64":
+		i, err := r.readInt(typeBits(t.Name))
+		if err != nil {
+			return err
+		}
+		return setInt(t, v, i)
+	case "UInt8", "UInt16", "UInt32", "UInt64":
+		u, err := r.readUint(typeBits(t.Name))
+		if err != nil {
+			return err
+		}
+		return setUint(t, v, u)
+	case "Int128", "Int256", "UInt128", "UInt256":
+		b, err := r.readBig(typeBits(t.Name), t.Name[0] == 'I')
+		if err != nil {
+			return err
+		}
+		return setBig(t, v, b)
+	case "Float32", "Float64":
+		u, err := r.readUint(typeBits(t.Name))
+		if err != nil {
+			return err
+		}
+		f := math.Float64frombits(u)
+		if t.Name == "Float32" {
+			f = float64(math.Float32frombits(uint32(u)))
+		}
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+		case reflect.String:
+			v.SetString(strconv.FormatFloat(f, 'g', -1, typeBits(t.Name)))
+		default:
+			return cannotDecode(t, v)
+		}
+		return nil
+	case "Bool":
+		b, err := r.read(1)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Bool:
+			v.SetBool(b[0] != 0)
+		default:
+			return setUint(t, v, uint64(b[0]))
+		}
+		return nil
+	case "String", "FixedString":
+		var (
+			b   []byte
+			err error
+		)
+		if t.Name == "String" {
+			b, err = r.readBytes()
+		} else {
+			b, err = r.read(t.Size)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(string(b))
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(append([]byte(nil), b...))
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() == len(b):
+			reflect.Copy(v, reflect.ValueOf(b))
+		default:
+			return cannotDecode(t, v)
+		}
+		return nil
+	case "Decimal":
+		d, err := r.readDecimal(t)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Type() == decimalType:
+			v.Set(reflect.ValueOf(d))
+		case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+			f, _ := strconv.ParseFloat(d.String(), 64)
+			v.SetFloat(f)
+		case v.Kind() == reflect.String:
+			v.SetString(d.String())
+		default:
+			if d, err = d.Rescale(0); err != nil {
+				return err
+			}
+			return setBig(t, v, d.unscaled())
+		}
+		return nil
+	case "Date", "Date32", "DateTime", "DateTime64":
+		tm, err := r.readTime(t)
+		if err != nil {
+			return err
+		}
+		switch v.Type() {
+		case timeType, dateType, date32Type, dateTimeType:
+			v.Set(reflect.ValueOf(tm).Convert(v.Type()))
+		case dateTime64Type:
+			v.Set(reflect.ValueOf(DateTime64{Time: tm, Precision: t.Precision}))
+		default:
+			switch v.Kind() {
+			case reflect.String:
+				v.SetString(formatBinaryTime(t, tm))
+			case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64:
+				return setInt(t, v, tm.Unix())
+			default:
+				return cannotDecode(t, v)
+			}
+		}
+		return nil
+	case "UUID":
+		u, err := r.readUUID()
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() == 16:
+			reflect.Copy(v, reflect.ValueOf(u[:]))
+		case v.Kind() == reflect.String:
+			v.SetString(u.String())
+		default:
+			return cannotDecode(t, v)
+		}
+		return nil
+	case "IPv4", "IPv6":
+		ip, err := r.readIP(t)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Type() == netIPType:
+			v.Set(reflect.ValueOf(ip))
+		case v.Type() == netipAddrType:
+			addr, _ := netip.AddrFromSlice(ip)
+			v.Set(reflect.ValueOf(addr.Unmap()))
+		case v.Kind() == reflect.String:
+			v.SetString(ip.String())
+		default:
+			return cannotDecode(t, v)
+		}
+		return nil
+	case "Enum8", "Enum16":
+		code, err := r.readInt(typeBits(t.Name))
+		if err != nil {
+			return err
+		}
+		if v.Kind() == reflect.String {
+			name, ok := t.Enum.Name(int16(code))
+			if !ok {
+				return fmt.Errorf("Unknown value %d of %s", code, t)
+			}
+			v.SetString(name)
+			return nil
+		}
+		return setInt(t, v, code)
+	case "Array", "Nested":
+		n, err := r.readUvarint()
+		if err != nil {
+			return err
+		}
+		elem := t.Args[0]
+		if t.Name == "Nested" {
+			elem = &ColumnType{Name: "Tuple", Args: t.Args, Names: t.Names, raw: t.raw}
+		}
+		switch v.Kind() {
+		case reflect.Slice:
+			if n > math.MaxInt32 {
+				return fmt.Errorf("Array length %d is too big", n)
+			}
+			v.Set(reflect.MakeSlice(v.Type(), int(n), int(n)))
+		case reflect.Array:
+			if uint64(v.Len()) != n {
+				return fmt.Errorf("Array of %d elements cannot be read into %s", n, v.Type())
+			}
+		default:
+			return cannotDecode(t, v)
+		}
+		for i := 0; i < int(n); i++ {
+			if err = r.decode(elem, v.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		return nil
+	case "Tuple":
+		switch v.Kind() {
+		case reflect.Struct:
+			fields := structFields(v.Type())
+			if len(fields) != len(t.Args) {
+				return fmt.Errorf("Type %s has %d fields, %s has %d elements", v.Type(), len(fields), t, len(t.Args))
+			}
+			for i, f := range fields {
+				if err := r.decode(t.Args[i], v.Field(f)); err != nil {
+					return fmt.Errorf("field %s: %v", v.Type().Field(f).Name, err)
+				}
+			}
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), len(t.Args), len(t.Args)))
+			for i, item := range t.Args {
+				if err := r.decode(item, v.Index(i)); err != nil {
+					return fmt.Errorf("element %d: %v", i, err)
+				}
+			}
+		default:
+			return cannotDecode(t, v)
+		}
+		return nil
+	case "Map":
+		n, err := r.readUvarint()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Map {
+			return cannotDecode(t, v)
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := uint64(0); i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err = r.decode(t.Args[0], key); err != nil {
+				return fmt.Errorf("key %d: %v", i, err)
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err = r.decode(t.Args[1], val); err != nil {
+				return fmt.Errorf("value of %v: %v", key, err)
+			}
+			v.SetMapIndex(key, val)
+		}
+		return nil
+	}
+	return fmt.Errorf("Type %s cannot be read from RowBinary", t)
+}
+
+// readValue decodes value into its natural Go type, it is used for interface{} destinations
+func (r *binaryReader) readValue(t *ColumnType) (interface{}, error) {
+	typ := naturalType(t)
+	if typ == interfaceType {
+		return nil, fmt.Errorf("Type %s cannot be read from RowBinary", t)
+	}
+	v := reflect.New(typ).Elem()
+	if err := r.decode(t, v); err != nil {
+		return nil, err
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	return v.Interface(), nil
+}
+
+func (r *binaryReader) readDecimal(t *ColumnType) (Decimal, error) {
+	var (
+		v   *big.Int
+		err error
+	)
+	switch bits := decimalBits(t.Precision); bits {
+	case 32, 64:
+		var i int64
+		i, err = r.readInt(bits)
+		v = big.NewInt(i)
+	default:
+		v, err = r.readBig(bits, true)
+	}
+	return Decimal{Value: v, Scale: t.Scale}, err
+}
+
+func (r *binaryReader) readTime(t *ColumnType) (time.Time, error) {
+	loc := r.location(t)
+	switch t.Name {
+	case "Date":
+		days, err := r.readUint(16)
+		return time.Date(1970, 1, 1+int(days), 0, 0, 0, 0, loc), err
+	case "Date32":
+		days, err := r.readInt(32)
+		return time.Date(1970, 1, 1+int(days), 0, 0, 0, 0, loc), err
+	case "DateTime":
+		sec, err := r.readUint(32)
+		return time.Unix(int64(sec), 0).In(loc), err
+	}
+	ticks, err := r.readInt(64)
+	scale := int64(math.Pow10(t.Precision))
+	sec := ticks / scale
+	if ticks%scale < 0 {
+		sec--
+	}
+	nsec := (ticks - sec*scale) * int64(math.Pow10(9-t.Precision))
+	return time.Unix(sec, nsec).In(loc), err
+}
+
+func (r *binaryReader) readUUID() (UUID, error) {
+	var u UUID
+	b, err := r.read(16)
+	if err != nil {
+		return u, err
+	}
+	for i := 0; i < 8; i++ {
+		u[i] = b[7-i]
+		u[8+i] = b[15-i]
+	}
+	return u, nil
+}
+
+func (r *binaryReader) readIP(t *ColumnType) (net.IP, error) {
+	if t.Name == "IPv4" {
+		u, err := r.readUint(32)
+		if err != nil {
+			return nil, err
+		}
+		return net.IPv4(byte(u>>24), byte(u>>16), byte(u>>8), byte(u)).To4(), nil
+	}
+	b, err := r.read(16)
+	if err != nil {
+		return nil, err
+	}
+	return append(net.IP(nil), b...), nil
+}
+
+// naturalType is Go type used for values scanned into interface{}
+func naturalType(t *ColumnType) reflect.Type {
+	switch t.Name {
+	case "Nullable", "LowCardinality":
+		return naturalType(t.Args[0])
+	case "Int8":
+		return reflect.TypeOf(int8(0))
+	case "Int16":
+		return reflect.TypeOf(int16(0))
+	case "Int32":
+		return reflect.TypeOf(int32(0))
+	case "Int64":
+		return reflect.TypeOf(int64(0))
+	case "UInt8":
+		return reflect.TypeOf(uint8(0))
+	case "UInt16":
+		return reflect.TypeOf(uint16(0))
+	case "UInt32":
+		return reflect.TypeOf(uint32(0))
+	case "UInt64":
+		return reflect.TypeOf(uint64(0))
+	case "Int128", "Int256", "UInt128", "UInt256":
+		return bigIntPtrType
+	case "Float32":
+		return reflect.TypeOf(float32(0))
+	case "Float64":
+		return reflect.TypeOf(float64(0))
+	case "Bool":
+		return reflect.TypeOf(false)
+	case "String", "FixedString", "Enum8", "Enum16":
+		return reflect.TypeOf("")
+	case "Decimal":
+		return decimalType
+	case "Date", "Date32", "DateTime", "DateTime64":
+		return timeType
+	case "UUID":
+		return uuidType
+	case "IPv4", "IPv6":
+		return netIPType
+	case "Array", "Nested":
+		return reflect.TypeOf(Array{})
+	case "Tuple":
+		return reflect.TypeOf(Tuple{})
+	case "Map":
+		if naturalType(t.Args[0]).Kind() == reflect.String {
+			return reflect.TypeOf(map[string]interface{}{})
+		}
+		return reflect.TypeOf(map[interface{}]interface{}{})
+	}
+	return interfaceType
+}
+
+// isBinaryType reports types decoded by binaryReader itself even if they implement unmarshaling interfaces
+func isBinaryType(t reflect.Type) bool {
+	switch t {
+	case timeType, uuidType, netIPType, netipAddrType, bigIntType, decimalType:
+		return true
+	}
+	return false
+}
+
+// unmarshalBinaryValue passes decoded value to custom type: sql.Scanner gets driver value, others get text
+func unmarshalBinaryValue(u interface{}, val interface{}) error {
+	if s, ok := u.(sql.Scanner); ok {
+		switch v := val.(type) {
+		case nil, int64, float64, bool, string, time.Time:
+			return s.Scan(v)
+		case int8, int16, int32, uint8, uint16, uint32:
+			return s.Scan(reflect.ValueOf(v).Convert(reflect.TypeOf(int64(0))).Interface())
+		case float32:
+			return s.Scan(float64(v))
+		}
+	}
+
+	var text string
+	switch v := val.(type) {
+	case string:
+		text = v
+	case time.Time:
+		text = v.Format(dateTimeLayout)
+	default:
+		text = fmt.Sprint(v)
+	}
+	switch u := u.(type) {
+	case ClickHouseUnmarshaler:
+		return u.UnmarshalClickHouse(text)
+	case sql.Scanner:
+		return u.Scan(text)
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText([]byte(text))
+	}
+	return nil
+}
+
+func setInt(t *ColumnType, v reflect.Value, i int64) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(i) {
+			return fmt.Errorf("Value %d of %s overflows %s", i, t, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return fmt.Errorf("Value %d of %s overflows %s", i, t, v.Type())
+		}
+		v.SetUint(uint64(i))
+	default:
+		return setBig(t, v, big.NewInt(i))
+	}
+	return nil
+}
+
+func setUint(t *ColumnType, v reflect.Value, u uint64) error {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.OverflowUint(u) {
+			return fmt.Errorf("Value %d of %s overflows %s", u, t, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Bool:
+		v.SetBool(u != 0)
+	default:
+		return setBig(t, v, new(big.Int).SetUint64(u))
+	}
+	return nil
+}
+
+func setBig(t *ColumnType, v reflect.Value, b *big.Int) error {
+	switch {
+	case v.Type() == bigIntPtrType:
+		v.Set(reflect.ValueOf(b))
+	case v.Type() == bigIntType:
+		v.Set(reflect.ValueOf(b).Elem())
+	case v.Type() == decimalType:
+		v.Set(reflect.ValueOf(Decimal{Value: b}))
+	case v.Kind() == reflect.String:
+		v.SetString(b.String())
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		f, _ := new(big.Float).SetInt(b).Float64()
+		v.SetFloat(f)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		if !b.IsInt64() || v.OverflowInt(b.Int64()) {
+			return fmt.Errorf("Value %s of %s overflows %s", b, t, v.Type())
+		}
+		v.SetInt(b.Int64())
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		if !b.IsUint64() || v.OverflowUint(b.Uint64()) {
+			return fmt.Errorf("Value %s of %s overflows %s", b, t, v.Type())
+		}
+		v.SetUint(b.Uint64())
+	default:
+		return cannotDecode(t, v)
+	}
+	return nil
+}
+
+func cannotDecode(t *ColumnType, v reflect.Value) error {
+	return fmt.Errorf("Type %s cannot be read into %s", t, v.Type())
+}
+
+func formatBinaryTime(t *ColumnType, tm time.Time) string {
+	switch t.Name {
+	case "Date", "Date32":
+		return tm.Format(dateLayout)
+	case "DateTime64":
+		if t.Precision > 0 {
+			return tm.Format(dateTimeLayout + "." + strings.Repeat("0", t.Precision))
+		}
+	}
+	return tm.Format(dateTimeLayout)
+}
+
+// typeBits returns size of numeric and Enum types
+func typeBits(name string) int {
+	switch name {
+	case "Int8", "UInt8", "Enum8":
+		return 8
+	case "Int16", "UInt16", "Enum16":
+		return 16
+	case "Int32", "UInt32", "Float32":
+		return 32
+	case "Int64", "UInt64", "Float64":
+		return 64
+	case "Int128", "UInt128":
+		return 128
+	}
+	return 256
+}
+
+func decimalBits(precision int) int {
+	switch {
+	case precision <= 9:
+		return 32
+	case precision <= 18:
+		return 64
+	case precision <= 38:
+		return 128
+	}
+	return 256
+}
@@ -0,0 +1,324 @@
+package clickhouse
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func binaryResponse(schema Schema, rows ...Row) string {
+	buf := &bytes.Buffer{}
+	enc, err := NewRowBinaryEncoder(buf, schema)
+	if err != nil {
+		panic(err)
+	}
+	enc.WriteHeader()
+	for _, row := range rows {
+		if err = enc.Encode(row); err != nil {
+			panic(err)
+		}
+	}
+	return buf.String()
+}
+
+func TestBinaryIter_Scan(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	u, _ := ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	schema := Schema{
+		{"i8", "Int8"}, {"u64", "UInt64"}, {"i256", "Int256"}, {"f", "Float64"}, {"b", "Bool"},
+		{"s", "LowCardinality(String)"}, {"fs", "FixedString(2)"}, {"d", "Decimal(18, 2)"},
+		{"date", "Date"}, {"dt", "DateTime"}, {"dt64", "DateTime64(3, 'Asia/Tokyo')"},
+		{"uuid", "UUID"}, {"ip4", "IPv4"}, {"ip6", "IPv6"}, {"e", "Enum8('a' = 1, 'b' = 2)"},
+		{"n", "Nullable(String)"}, {"arr", "Array(Nullable(Int32))"}, {"tup", "Tuple(String, UInt8)"},
+		{"m", "Map(String, Array(UInt8))"},
+	}
+	resp := binaryResponse(schema, Row{
+		-5, uint64(1 << 63), new(big.Int).Lsh(big.NewInt(-1), 200), 0.1, true,
+		"str", "ab", NewDecimal(-12345, 2),
+		tm, tm, tm,
+		u, "1.2.3.4", "::1", "b",
+		nil, []interface{}{1, nil}, Tuple{"x", 7},
+		map[string][]uint8{"k": {1, 2}},
+	})
+
+	conn := NewConn(getHost(), getMockTransport(resp))
+	iter := NewQuery("SELECT *").BinaryIter(conn)
+	assert.NoError(t, iter.Error())
+	assert.Equal(t, schema, iter.Columns())
+
+	var (
+		i8   int
+		u64  uint64
+		i256 *big.Int
+		f    float64
+		b    bool
+		s    string
+		fs   [2]byte
+		d    Decimal
+		date Date
+		dt   time.Time
+		dt64 DateTime64
+		uid  UUID
+		ip4  net.IP
+		ip6  netip.Addr
+		e    int8
+		n    sql.NullString
+		arr  []*int32
+		tup  struct {
+			S string
+			N int
+		}
+		m map[string][]int
+	)
+	assert.True(t, iter.Scan(&i8, &u64, &i256, &f, &b, &s, &fs, &d, &date, &dt, &dt64, &uid, &ip4, &ip6, &e, &n, &arr, &tup, &m))
+	assert.NoError(t, iter.Error())
+	assert.Equal(t, -5, i8)
+	assert.Equal(t, uint64(1<<63), u64)
+	assert.Equal(t, new(big.Int).Lsh(big.NewInt(-1), 200), i256)
+	assert.Equal(t, 0.1, f)
+	assert.True(t, b)
+	assert.Equal(t, "str", s)
+	assert.Equal(t, [2]byte{'a', 'b'}, fs)
+	assert.Equal(t, "-123.45", d.String())
+	assert.Equal(t, "2020-01-02", time.Time(date).Format(dateLayout))
+	assert.True(t, tm.Truncate(time.Second).Equal(dt))
+	assert.True(t, tm.Equal(dt64.Time))
+	assert.Equal(t, "Asia/Tokyo", dt64.Time.Location().String())
+	assert.Equal(t, 3, dt64.Precision)
+	assert.Equal(t, u, uid)
+	assert.Equal(t, "1.2.3.4", ip4.String())
+	assert.Equal(t, "::1", ip6.String())
+	assert.Equal(t, int8(2), e)
+	assert.False(t, n.Valid)
+	assert.Equal(t, int32(1), *arr[0])
+	assert.Nil(t, arr[1])
+	assert.Equal(t, "x", tup.S)
+	assert.Equal(t, 7, tup.N)
+	assert.Equal(t, map[string][]int{"k": {1, 2}}, m)
+
+	assert.False(t, iter.Scan(&i8))
+	assert.NoError(t, iter.Error())
+}
+
+func TestBinaryIter_ScanInterface(t *testing.T) {
+	schema := Schema{{"a", "UInt8"}, {"b", "Nullable(String)"}, {"c", "Array(String)"}, {"d", "Tuple(Int64, Enum8('x' = 1))"}, {"e", "Map(String, Float32)"}}
+	resp := binaryResponse(schema, Row{1, nil, []string{"s"}, Tuple{-1, "x"}, map[string]float32{"k": 0.5}})
+	iter := NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp)))
+
+	var a, b, c, d, e interface{}
+	assert.True(t, iter.Scan(&a, &b, &c, &d, &e))
+	assert.Equal(t, uint8(1), a)
+	assert.Nil(t, b)
+	assert.Equal(t, Array{"s"}, c)
+	assert.Equal(t, Tuple{int64(-1), "x"}, d)
+	assert.Equal(t, map[string]interface{}{"k": float32(0.5)}, e)
+}
+
+func TestBinaryIter_ScanNull(t *testing.T) {
+	schema := Schema{{"n", "Nullable(String)"}}
+	resp := binaryResponse(schema, Row{nil})
+
+	var s string
+	iter := NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp)))
+	assert.False(t, iter.Scan(&s))
+	assert.Error(t, iter.Error())
+
+	var ns sql.NullString
+	iter = NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp)))
+	assert.True(t, iter.Scan(&ns))
+	assert.False(t, ns.Valid)
+}
+
+func TestBinaryIter_ScanStruct(t *testing.T) {
+	schema := Schema{{"id", "UInt32"}, {"user_name", "String"}, {"skipped", "Array(String)"}, {"Score", "Float32"}}
+	resp := binaryResponse(schema, Row{1, "a", []string{"x"}, 1.5}, Row{2, "b", []string{}, 2})
+	iter := NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp)))
+
+	type row struct {
+		ID    int    `ch:"id"`
+		Name  string `json:"user_name,omitempty"`
+		Score float64
+	}
+	var res []row
+	var r row
+	for iter.ScanStruct(&r) {
+		res = append(res, r)
+	}
+	assert.NoError(t, iter.Error())
+	assert.Equal(t, []row{{1, "a", 1.5}, {2, "b", 2}}, res)
+}
+
+func TestBinaryIter_Errors(t *testing.T) {
+	schema := Schema{{"a", "Int16"}, {"b", "String"}}
+	resp := binaryResponse(schema, Row{300, "s"})
+
+	var (
+		i8 int8
+		s  string
+	)
+	iter := NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp)))
+	assert.False(t, iter.Scan(&i8, &s))
+	assert.Error(t, iter.Error())
+
+	iter = NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp[:len(resp)-1])))
+	var i16 int16
+	assert.False(t, iter.Scan(&i16, &s))
+	assert.Error(t, iter.Error())
+
+	iter = NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport(resp)))
+	assert.False(t, iter.Scan(&i16, &s, &s))
+	assert.Error(t, iter.Error())
+
+	iter = NewQuery("SELECT *").BinaryIter(NewConn(getHost(), getMockTransport("Code: 62, e.displayText() = DB::Exception: Syntax error")))
+	assert.Error(t, iter.Error())
+	assert.Equal(t, 62, iter.Error().(*DbError).Code())
+	assert.False(t, iter.Scan(&i16))
+
+	iter = NewQuery("SELECT *").BinaryIter(nil)
+	assert.Error(t, iter.Error())
+}
+
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		err = r.err
+	}
+	return n, err
+}
+
+func TestBinaryIter_StreamErrors(t *testing.T) {
+	schema := Schema{{"n", "UInt64"}, {"s", "String"}}
+	resp := binaryResponse(schema, Row{1, "a"}, Row{2, "b"})
+	var (
+		n uint64
+		s string
+	)
+
+	// server writes exception after some rows were sent
+	exception := "Code: 241. DB::Exception: Memory limit (for query) exceeded\n"
+	iter := newBinaryIter(io.NopCloser(strings.NewReader(resp[:len(resp)-2]+exception)), nil)
+	assert.True(t, iter.Scan(&n, &s))
+	assert.False(t, iter.Scan(&n, &s))
+	if assert.IsType(t, &DbError{}, iter.Error()) {
+		assert.Equal(t, 241, iter.Error().(*DbError).Code())
+	}
+
+	// network errors are kept
+	netErr := errors.New("connection reset by peer")
+	iter = newBinaryIter(io.NopCloser(&failingReader{strings.NewReader(resp[:len(resp)-3]), netErr}), nil)
+	assert.True(t, iter.Scan(&n, &s))
+	assert.False(t, iter.Scan(&n, &s))
+	if assert.Error(t, iter.Error()) {
+		assert.Contains(t, iter.Error().Error(), "connection reset by peer")
+	}
+}
+
+func TestBinaryIter_Stream(t *testing.T) {
+	schema := Schema{{"n", "UInt64"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		if !strings.HasSuffix(string(query), "FORMAT RowBinaryWithNamesAndTypes") {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "Code: 62, e.displayText() = DB::Exception: Syntax error")
+			return
+		}
+		fmt.Fprint(w, binaryResponse(schema, Row{1}, Row{2}))
+	}))
+	defer server.Close()
+
+	conn := NewConn(server.URL, NewHttpTransport())
+	iter := NewQuery("SELECT number FROM system.numbers LIMIT 2").BinaryIter(conn)
+	var n, sum uint64
+	for iter.Scan(&n) {
+		sum += n
+	}
+	assert.NoError(t, iter.Error())
+	assert.Equal(t, uint64(3), sum)
+
+	_, err := conn.Stream(NewQuery("SELECT 1"), false)
+	assert.Error(t, err)
+	assert.Equal(t, 62, err.(*DbError).Code())
+}
+
+func TestIter_ScanStruct(t *testing.T) {
+	iter := NewQuery("SELECT id, name").Iter(NewConn(getHost(), getMockTransport("1\ta\n2\tb")))
+	var r struct {
+		ID   int
+		Name string
+		skip string
+	}
+	assert.True(t, iter.ScanStruct(&r))
+	assert.Equal(t, 1, r.ID)
+	assert.Equal(t, "a", r.Name)
+	assert.True(t, iter.ScanStruct(&r))
+	assert.Equal(t, "b", r.Name)
+	assert.False(t, iter.ScanStruct(&r))
+
+	assert.False(t, iter.ScanStruct(r))
+	assert.Error(t, iter.Error())
+}
+
+var benchmarkSchema = Schema{{"id", "UInt64"}, {"price", "Float64"}, {"name", "String"}, {"created", "DateTime"}}
+
+func benchmarkRows() []Row {
+	rows := make([]Row, 1000)
+	for i := range rows {
+		rows[i] = Row{uint64(i), float64(i) / 3, fmt.Sprintf("name %d", i), time.Unix(int64(1600000000+i), 0)}
+	}
+	return rows
+}
+
+func BenchmarkIter_Scan(b *testing.B) {
+	lines := make([]string, 0, 1000)
+	for _, row := range benchmarkRows() {
+		lines = append(lines, fmt.Sprintf("%d\t%v\t%s\t%s", row[0], row[1], row[2], row[3].(time.Time).UTC().Format(dateTimeLayout)))
+	}
+	conn := NewConn(getHost(), getMockTransport(strings.Join(lines, "\n")))
+	b.ResetTimer()
+
+	var (
+		id      uint64
+		price   float64
+		name    string
+		created time.Time
+	)
+	for i := 0; i < b.N; i++ {
+		iter := NewQuery("SELECT *").Iter(conn)
+		for iter.Scan(&id, &price, &name, &created) {
+		}
+	}
+}
+
+func BenchmarkBinaryIter_Scan(b *testing.B) {
+	conn := NewConn(getHost(), getMockTransport(binaryResponse(benchmarkSchema, benchmarkRows()...)))
+	b.ResetTimer()
+
+	var (
+		id      uint64
+		price   float64
+		name    string
+		created time.Time
+	)
+	for i := 0; i < b.N; i++ {
+		iter := NewQuery("SELECT *").BinaryIter(conn)
+		for iter.Scan(&id, &price, &name, &created) {
+		}
+	}
+}
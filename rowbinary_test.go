@@ -0,0 +1,182 @@
+package clickhouse
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeBinary(typ string, value interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc, err := NewRowBinaryEncoder(buf, Schema{{"c", typ}})
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Encode(Row{value})
+	return buf.Bytes(), err
+}
+
+func TestRowBinaryEncoder_Types(t *testing.T) {
+	u, _ := ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	big128, _ := new(big.Int).SetString("-2", 10)
+
+	cases := []struct {
+		typ      string
+		value    interface{}
+		expected []byte
+	}{
+		{"Int8", -1, []byte{0xff}},
+		{"Int16", int16(-2), []byte{0xfe, 0xff}},
+		{"UInt32", uint(1), []byte{1, 0, 0, 0}},
+		{"UInt64", uint64(1 << 63), []byte{0, 0, 0, 0, 0, 0, 0, 0x80}},
+		{"UInt8", true, []byte{1}},
+		{"Int128", big128, append([]byte{0xfe}, bytes.Repeat([]byte{0xff}, 15)...)},
+		{"UInt256", 1, append([]byte{1}, make([]byte, 31)...)},
+		{"Float32", float32(1), []byte{0, 0, 0x80, 0x3f}},
+		{"Float64", 2, []byte{0, 0, 0, 0, 0, 0, 0, 0x40}},
+		{"Bool", true, []byte{1}},
+		{"String", "abc", []byte{3, 'a', 'b', 'c'}},
+		{"String", []byte("ab"), []byte{2, 'a', 'b'}},
+		{"FixedString(4)", "ab", []byte{'a', 'b', 0, 0}},
+		{"LowCardinality(String)", "a", []byte{1, 'a'}},
+		{"Nullable(String)", nil, []byte{1}},
+		{"Nullable(String)", sql.NullString{String: "a", Valid: true}, []byte{0, 1, 'a'}},
+		{"Nullable(Int8)", (*int)(nil), []byte{1}},
+		{"Nullable(String)", (*sql.NullString)(nil), []byte{1}},
+		{"Nullable(DateTime)", (*time.Time)(nil), []byte{1}},
+		{"LowCardinality(Nullable(String))", nil, []byte{1}},
+		{"LowCardinality(Nullable(String))", "a", []byte{0, 1, 'a'}},
+		{"Decimal(9, 2)", "1.5", []byte{150, 0, 0, 0}},
+		{"Decimal(18, 2)", NewDecimal(-1, 2), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"Decimal(10, 1)", 1, []byte{10, 0, 0, 0, 0, 0, 0, 0}},
+		{"Decimal128(0)", 1.0, append([]byte{1}, make([]byte, 15)...)},
+		{"Decimal(9, 2)", float32(1.1), []byte{110, 0, 0, 0}},
+		{"Date", Date(tm), []byte{0x57, 0x47}},
+		{"Date32", time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), []byte{0xff, 0xff, 0xff, 0xff}},
+		{"DateTime", tm, []byte{0xa5, 0x5d, 0x0d, 0x5e}},
+		{"DateTime('Asia/Tokyo')", DateTime(tm), []byte{0xa5, 0x5d, 0x0d, 0x5e}},
+		{"DateTime64(3)", tm, []byte{0x03, 0xcd, 0x35, 0x64, 0x6f, 0x01, 0, 0}},
+		{"UUID", u, []byte{0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00, 0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88}},
+		{"UUID", u.String(), []byte{0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00, 0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88}},
+		{"IPv4", net.ParseIP("1.2.3.4"), []byte{4, 3, 2, 1}},
+		{"IPv6", "::1", append(make([]byte, 15), 1)},
+		{"Enum8('a' = 1, 'b' = -1)", "b", []byte{0xff}},
+		{"Enum16('a' = 1, 'b' = 2)", 2, []byte{2, 0}},
+		{"Array(Nullable(UInt8))", []interface{}{1, nil}, []byte{2, 0, 1, 1}},
+		{"Array(UInt8)", []byte{1, 2}, []byte{2, 1, 2}},
+		{"Tuple(String, UInt8)", Tuple{"a", 1}, []byte{1, 'a', 1}},
+		{"Tuple(a String, b UInt8)", struct {
+			A string
+			B int
+		}{"a", 1}, []byte{1, 'a', 1}},
+		{"Map(String, UInt16)", map[string]int{"a": 1}, []byte{1, 1, 'a', 1, 0}},
+		{"Nested(a UInt8, b String)", []Tuple{{1, "x"}}, []byte{1, 1, 1, 'x'}},
+	}
+	for _, c := range cases {
+		res, err := encodeBinary(c.typ, c.value)
+		if assert.NoError(t, err, c.typ) {
+			assert.Equal(t, c.expected, res, fmt.Sprintf("%s %v", c.typ, c.value))
+		}
+	}
+}
+
+func TestRowBinaryEncoder_Errors(t *testing.T) {
+	cases := []struct {
+		typ   string
+		value interface{}
+	}{
+		{"Int8", 128},
+		{"UInt8", -1},
+		{"UInt32", uint64(1 << 32)},
+		{"Int64", uint64(1 << 63)},
+		{"Int128", new(big.Int).Lsh(big.NewInt(1), 127)},
+		{"String", nil},
+		{"String", 1},
+		{"FixedString(1)", "ab"},
+		{"Decimal(9, 2)", "1.234"},
+		{"Decimal(3, 2)", 10},
+		{"Date", time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"IPv4", "::1"},
+		{"Enum8('a' = 1)", "b"},
+		{"Tuple(String, UInt8)", Tuple{"a"}},
+		{"Array(UInt8)", 1},
+	}
+	for _, c := range cases {
+		res, err := encodeBinary(c.typ, c.value)
+		assert.Error(t, err, "%s %v", c.typ, c.value)
+		assert.Empty(t, res)
+	}
+
+	_, err := NewRowBinaryEncoder(io.Discard, Schema{{"c", "Object('json')"}})
+	assert.Error(t, err)
+
+	enc, _ := NewRowBinaryEncoder(io.Discard, Schema{{"c", "UInt8"}})
+	assert.Error(t, enc.Encode(Row{1, 2}))
+}
+
+func TestRowBinaryEncoder_WriteHeader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc, err := NewRowBinaryEncoder(buf, Schema{{"id", "UInt8"}, {"s", "String"}})
+	assert.NoError(t, err)
+	assert.NoError(t, enc.WriteHeader())
+	assert.NoError(t, enc.Encode(Row{1, "a"}))
+	assert.Equal(t, []byte("\x02\x02id\x01s\x05UInt8\x06String\x01\x01a"), buf.Bytes())
+}
+
+type insertHandler struct {
+	query string
+	body  []byte
+	resp  string
+}
+
+func (h *insertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.query = r.URL.Query().Get("query")
+	h.body, _ = io.ReadAll(r.Body)
+	fmt.Fprint(w, h.resp)
+}
+
+func TestRowBinaryInsert(t *testing.T) {
+	handler := &insertHandler{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	conn := NewConn(server.URL, NewHttpTransport())
+	schema := Schema{{"id", "UInt8"}, {"tags", "Array(String)"}}
+	ins, err := NewRowBinaryInsert(conn, "t", FormatRowBinary, schema)
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Write(Row{1, []string{"a"}}))
+	assert.NoError(t, ins.Write(Row{2, []string{}}))
+	assert.NoError(t, ins.Close())
+
+	assert.Equal(t, "INSERT INTO t (id,tags) FORMAT RowBinary", handler.query)
+	assert.Equal(t, []byte{1, 1, 1, 'a', 2, 0}, handler.body)
+
+	handler.resp = "Code: 27, e.displayText() = DB::Exception: Cannot parse input, e.what() = DB::Exception"
+	ins, err = NewRowBinaryInsert(conn, "t", FormatRowBinaryWithNamesAndTypes, schema)
+	assert.NoError(t, err)
+	assert.NoError(t, ins.Write(Row{1, []string{"a"}}))
+	err = ins.Close()
+	assert.Error(t, err)
+	assert.Equal(t, 27, err.(*DbError).Code())
+
+	_, err = NewRowBinaryInsert(conn, "t", "CSV", schema)
+	assert.Error(t, err)
+}
+
+func BenchmarkRowBinaryEncoder(b *testing.B) {
+	enc, _ := NewRowBinaryEncoder(io.Discard, Schema{{"id", "UInt64"}, {"time", "DateTime"}, {"url", "String"}, {"tags", "Array(LowCardinality(String))"}, {"price", "Decimal(18, 4)"}})
+	row := Row{uint64(1), time.Now(), "https://example.com/", []string{"a", "b"}, NewDecimal(12345, 4)}
+	for i := 0; i < b.N; i++ {
+		enc.Encode(row)
+	}
+}
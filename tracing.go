@@ -0,0 +1,93 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Tracer starts span for each request, see package chotel for OpenTelemetry adapter
+type Tracer interface {
+	// Start starts span with parent from ctx and returns context with new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is one traced request
+type Span interface {
+	// SetAttribute sets string, int64 or uint64 attribute
+	SetAttribute(key string, value interface{})
+	// TraceParent returns W3C trace context of span, server links its own spans to it.
+	// Empty value is not sent
+	TraceParent() string
+	// End finishes span, err is nil for successful request
+	End(err error)
+}
+
+// TracingOptions control Trace interceptor
+type TracingOptions struct {
+	// Redact changes statement before it is recorded, e.g. removes literals. Nil records statement as is,
+	// bound :value: arguments are never recorded
+	Redact func(stmt string) string
+	// NoStatement disables recording of statement
+	NoStatement bool
+}
+
+// Span attributes set by Trace
+const (
+	AttrSystem       = "db.system"
+	AttrStatement    = "db.statement"
+	AttrHost         = "server.address"
+	AttrKind         = "clickhouse.call"
+	AttrQueryID      = "clickhouse.query_id"
+	AttrReadRows     = "clickhouse.read_rows"
+	AttrReadBytes    = "clickhouse.read_bytes"
+	AttrWrittenRows  = "clickhouse.written_rows"
+	AttrWrittenBytes = "clickhouse.written_bytes"
+	AttrErrorCode    = "clickhouse.error_code"
+)
+
+// Trace returns interceptor which makes span for every request of connection:
+//
+//	conn.Use(clickhouse.Trace(chotel.NewTracer(otel.Tracer("clickhouse")), clickhouse.TracingOptions{}))
+//
+// Parent span is taken from query context, trace context is sent to server in traceparent header.
+// Span of streaming request is finished when response headers are received
+func Trace(tracer Tracer, opts TracingOptions) Interceptor {
+	return func(next Handler) Handler {
+		return func(call *Call) (io.ReadCloser, error) {
+			ctx, span := tracer.Start(call.Query.Context(), "clickhouse."+call.Kind)
+			call.Query.SetContext(ctx)
+			span.SetAttribute(AttrSystem, "clickhouse")
+			span.SetAttribute(AttrHost, call.Host)
+			span.SetAttribute(AttrKind, call.Kind)
+			if !opts.NoStatement {
+				stmt := call.Query.Stmt
+				if opts.Redact != nil {
+					stmt = opts.Redact(stmt)
+				}
+				span.SetAttribute(AttrStatement, stmt)
+			}
+			if parent := span.TraceParent(); parent != "" {
+				call.SetHeader("traceparent", parent)
+			}
+
+			body, err := next(call)
+
+			if id := call.QueryID(); id != "" {
+				span.SetAttribute(AttrQueryID, id)
+			}
+			if summary, ok := call.Summary(); ok {
+				span.SetAttribute(AttrReadRows, summary.ReadRows)
+				span.SetAttribute(AttrReadBytes, summary.ReadBytes)
+				span.SetAttribute(AttrWrittenRows, summary.WrittenRows)
+				span.SetAttribute(AttrWrittenBytes, summary.WrittenBytes)
+			}
+			var dbErr *DbError
+			if errors.As(err, &dbErr) {
+				span.SetAttribute(AttrErrorCode, int64(dbErr.Code()))
+			}
+			span.End(err)
+			return body, err
+		}
+	}
+}
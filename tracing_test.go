@@ -0,0 +1,99 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+type spanKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &testSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *testSpan) TraceParent() string {
+	return "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+}
+
+func (s *testSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+func TestTrace(t *testing.T) {
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		w.Header().Set("X-ClickHouse-Query-Id", "qid-1")
+		w.Header().Set("X-ClickHouse-Summary", `{"read_rows":"10","read_bytes":"800","written_rows":"0","written_bytes":"0","total_rows_to_read":"10"}`)
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist")
+			return
+		}
+		io.WriteString(w, "1\n")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	tracer := &testTracer{}
+	conn.Use(Trace(tracer, TracingOptions{Redact: strings.ToUpper}))
+
+	q := NewQuery("select :value:", "secret")
+	assert.NoError(t, q.Exec(conn))
+	if assert.Len(t, tracer.spans, 1) {
+		s := tracer.spans[0]
+		assert.Equal(t, "clickhouse.exec", s.name)
+		assert.True(t, s.ended)
+		assert.NoError(t, s.err)
+		assert.Equal(t, "clickhouse", s.attrs[AttrSystem])
+		assert.Equal(t, "SELECT :VALUE:", s.attrs[AttrStatement])
+		assert.Equal(t, conn.Host, s.attrs[AttrHost])
+		assert.Equal(t, "qid-1", s.attrs[AttrQueryID])
+		assert.Equal(t, uint64(10), s.attrs[AttrReadRows])
+		assert.Equal(t, uint64(800), s.attrs[AttrReadBytes])
+	}
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", traceParent)
+
+	_, err := NewQuery("SELECT * FROM missing").Stream(conn)
+	assert.Error(t, err)
+	if assert.Len(t, tracer.spans, 2) {
+		s := tracer.spans[1]
+		assert.Equal(t, "clickhouse.stream", s.name)
+		assert.Equal(t, err, s.err)
+		assert.Equal(t, int64(60), s.attrs[AttrErrorCode])
+	}
+
+	tracer.spans = nil
+	conn = NewConn(server.URL, NewHttpTransport())
+	conn.Use(Trace(tracer, TracingOptions{NoStatement: true}))
+	assert.NoError(t, conn.Ping())
+	if assert.Len(t, tracer.spans, 1) {
+		assert.NotContains(t, tracer.spans[0].attrs, AttrStatement)
+		assert.Equal(t, CallPing, tracer.spans[0].attrs[AttrKind])
+	}
+}
@@ -2,6 +2,9 @@ package clickhouse
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -22,10 +25,13 @@ type Transport interface {
 
 // HttpTransport use http.Client for connections
 type HttpTransport struct {
-	client *http.Client
+	client  *http.Client
+	metrics MetricsCollector
 }
 
-// NewHttpTransport creates default http transport with 30sec timeout
+// NewHttpTransport creates default http transport with 30sec timeout.
+// Timeout is not applied to streamed requests (Stream, iterators, exports and inserts from reader),
+// they run until query context is done
 func NewHttpTransport() HttpTransport {
 	default_client := &http.Client{
 		Timeout: 30 * time.Second,
@@ -34,22 +40,89 @@ func NewHttpTransport() HttpTransport {
 }
 
 // NewCustomTransport creates HttpTransport with custom client. Usefull for special timeouts, tls config etc.
+// Client Timeout is skipped for streamed requests, use query context to limit them
 func NewCustomTransport(client *http.Client) HttpTransport {
 	return HttpTransport{
 		client: client,
 	}
 }
 
+// StreamTransport is implemented by transports which can return response body without reading it into memory
+type StreamTransport interface {
+	Stream(host, params string, q Query, readOnly bool) (io.ReadCloser, error)
+}
+
 // Exec make http request with all params. readOnly param controls GET/POST request
 func (t HttpTransport) Exec(host, params string, q Query, readOnly bool) (res string, err error) {
-	var resp *http.Response
-	query := prepareHttp(q.Stmt, q.args)
+	if q.kind == "" {
+		q.kind = CallExec
+	}
+	resp, err := t.do(host, params, q, readOnly)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(resp.Body)
+	if resp.StatusCode != http.StatusOK && err == nil {
+		// response without exception is failed too, e.g. 503 of proxy, so insert is not taken as written
+		err = statusError(resp, buf.String())
+		setResponseError(resp, err)
+	}
+
+	return buf.String(), err
+}
+
+// Stream makes same request as Exec, but returns body to read it while server sends data
+func (t HttpTransport) Stream(host, params string, q Query, readOnly bool) (io.ReadCloser, error) {
+	if q.kind == "" {
+		q.kind = CallStream
+	}
+	resp, err := t.do(host, params, q, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		buf := new(bytes.Buffer)
+		if _, err = buf.ReadFrom(resp.Body); err != nil {
+			return nil, err
+		}
+		err = statusError(resp, buf.String())
+		setResponseError(resp, err)
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// statusError returns server exception or error with status of failed response
+func statusError(resp *http.Response, body string) error {
+	if err := errorFromResponse(body); err != nil {
+		return err
+	}
+	return fmt.Errorf("Clickhouse host response status is %s: %s", resp.Status, body)
+}
+
+func (t HttpTransport) do(host, params string, q Query, readOnly bool) (*http.Response, error) {
+	query, err := prepareQuery(q)
+	if err != nil {
+		return nil, err
+	}
 
 	if readOnly {
 		if len(query) > 0 {
 			query = "?query=" + query
 		}
 
+		if extra := q.params.Encode(); len(extra) > 0 {
+			if len(params) > 0 {
+				params = extra + "&" + params
+			} else {
+				params = extra
+			}
+		}
+
 		if len(params) > 0 {
 			if len(query) > 0 {
 				query += "&" + params
@@ -58,36 +131,90 @@ func (t HttpTransport) Exec(host, params string, q Query, readOnly bool) (res st
 			}
 		}
 
-		resp, err = t.client.Get(host + query)
-	} else {
-		var req *http.Request
-
-		// Set global parameters for query, like: user, password, max_memory_limit, etc.
-		// But it skips already defined params.
-		req, err = prepareExecPostRequest(host, params, q)
-
+		req, err := http.NewRequestWithContext(q.Context(), "GET", host+query, nil)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		resp, err = t.client.Do(req)
+		return t.send(req, host, q)
 	}
 
+	// Set global parameters for query, like: user, password, max_memory_limit, etc.
+	// But it skips already defined params.
+	req, err := prepareExecPostRequest(host, params, q)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return t.send(req, host, q)
+}
+
+// send adds query headers to request, saves response headers for interceptors and reports metrics
+func (t HttpTransport) send(req *http.Request, host string, q Query) (*http.Response, error) {
+	for name, values := range q.header {
+		req.Header[name] = values
+	}
+	client := t.client
+	if q.kind == CallStream || q.body != nil {
+		client = t.streamClient()
+	}
+	if t.metrics == nil {
+		resp, err := client.Do(req)
+		if err == nil && q.response != nil {
+			q.response.status, q.response.header = resp.StatusCode, resp.Header
+		}
+		return resp, err
 	}
 
-	defer resp.Body.Close()
-	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(resp.Body)
+	start := time.Now()
+	statement := StatementKind(q.Stmt)
+	t.metrics.RequestStarted(host, q.kind, statement)
+	var sent *countingReader
+	if req.Body != nil {
+		sent = &countingReader{ReadCloser: req.Body}
+		req.Body = sent
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.metrics.RequestFinished(host, q.kind, statement, time.Since(start), sent.count(), 0, err)
+		return nil, err
+	}
+	if q.response != nil {
+		q.response.status, q.response.header = resp.StatusCode, resp.Header
+	}
+	resp.Body = &meteredBody{ReadCloser: resp.Body, metrics: t.metrics, host: host, kind: q.kind, statement: statement, start: start, sent: sent}
+	return resp, nil
+}
 
-	return buf.String(), err
+// streamClient returns client without Timeout, it would break long streams in the middle
+func (t HttpTransport) streamClient() *http.Client {
+	if t.client.Timeout == 0 {
+		return t.client
+	}
+	client := *t.client
+	client.Timeout = 0
+	return &client
 }
 
 func prepareExecPostRequest(host, paramsCon string, q Query) (*http.Request, error) {
-	query := prepareHttp(q.Stmt, q.args)
+	query, err := prepareQuery(q)
+	if err != nil {
+		return nil, err
+	}
 	var req *http.Request
-	var err error = nil
-	if len(q.externals) > 0 {
+	if q.body != nil {
+		// statement goes to url, so body can be streamed without buffering
+		query = "?query=" + url.QueryEscape(query)
+		if params := q.params.Encode(); len(params) > 0 {
+			query += "&" + params
+		}
+		if len(paramsCon) > 0 {
+			query += "&" + paramsCon
+		}
+		req, err = http.NewRequestWithContext(q.Context(), "POST", host+query, q.body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+	} else if len(q.externals) > 0 {
 		if len(query) > 0 {
 			query = "?query=" + url.QueryEscape(query)
 		}
@@ -122,13 +249,20 @@ func prepareExecPostRequest(host, paramsCon string, q Query) (*http.Request, err
 			return nil, err
 		}
 
-		req, err = http.NewRequest("POST", host+query, body)
+		req, err = http.NewRequestWithContext(q.Context(), "POST", host+query, body)
 		if err != nil {
 			return nil, err
 		}
 		req.Header.Set("Content-Type", writer.FormDataContentType())
 	} else {
-		req, err = http.NewRequest("POST", host+"?"+paramsCon, strings.NewReader(query))
+		if params := q.params.Encode(); len(params) > 0 {
+			if len(paramsCon) > 0 {
+				paramsCon = params + "&" + paramsCon
+			} else {
+				paramsCon = params
+			}
+		}
+		req, err = http.NewRequestWithContext(q.Context(), "POST", host+"?"+paramsCon, strings.NewReader(query))
 		if err != nil {
 			return nil, err
 		}
@@ -137,9 +271,30 @@ func prepareExecPostRequest(host, paramsCon string, q Query) (*http.Request, err
 	return req, err
 }
 
-func prepareHttp(stmt string, args []interface{}) string {
-	if len(args) == 0 {
-		return stmt
+// prepareQuery binds arguments of q, literals already rendered by BuildMultiInsert are reused
+func prepareQuery(q Query) (string, error) {
+	if len(q.args) > 0 && len(q.literals) == len(q.args) {
+		return bindLiterals(q.Stmt, len(q.literals), func(k int) (string, error) {
+			return q.literals[k], nil
+		})
+	}
+	return prepareHttp(q.Stmt, q.args)
+}
+
+func prepareHttp(stmt string, args []interface{}) (string, error) {
+	return bindLiterals(stmt, len(args), func(k int) (string, error) {
+		val, err := marshal(args[k])
+		if err != nil {
+			return "", fmt.Errorf("argument %d: %v", k, err)
+		}
+		return val, nil
+	})
+}
+
+// bindLiterals replaces :value: placeholders of stmt with n literals
+func bindLiterals(stmt string, n int, literal func(k int) (string, error)) (string, error) {
+	if n == 0 {
+		return stmt, nil
 	}
 
 	var res []byte
@@ -160,8 +315,15 @@ func prepareHttp(stmt string, args []interface{}) string {
 			skip_to = -1
 		}
 
-		if ch == ':' && stmt[key:key+7] == ":value:" {
-			res = append(res, []byte(marshal(args[k]))...)
+		if ch == ':' && strings.HasPrefix(stmt[key:], ":value:") {
+			if k >= n {
+				return "", errors.New("Not enough arguments for :value: placeholders")
+			}
+			val, err := literal(k)
+			if err != nil {
+				return "", err
+			}
+			res = append(res, val...)
 			k++
 			skip_to = key + 7
 		} else {
@@ -169,5 +331,5 @@ func prepareHttp(stmt string, args []interface{}) string {
 		}
 	}
 
-	return string(res)
+	return string(res), nil
 }
@@ -1,7 +1,9 @@
 package clickhouse
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/stretchr/testify/assert"
 
@@ -10,6 +12,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 type TestHandler struct {
@@ -43,7 +46,8 @@ func TestExecReadOnly(t *testing.T) {
 	transport := NewHttpTransport()
 	conn := Conn{Host: server.URL, transport: transport}
 	q := NewQuery(url.QueryEscape("SELECT * FROM testdata"))
-	query := prepareHttp(q.Stmt, q.args)
+	query, err := prepareHttp(q.Stmt, q.args)
+	assert.NoError(t, err)
 	query = "?query=" + url.QueryEscape(query)
 	resp, err := conn.Exec(q, true)
 	assert.Equal(t, nil, err)
@@ -52,12 +56,14 @@ func TestExecReadOnly(t *testing.T) {
 }
 
 func TestPrepareHttp(t *testing.T) {
-	p := prepareHttp("SELECT * FROM table WHERE key = :value:", []interface{}{"test"})
+	p, err := prepareHttp("SELECT * FROM table WHERE key = :value:", []interface{}{"test"})
+	assert.NoError(t, err)
 	assert.Equal(t, "SELECT * FROM table WHERE key = 'test'", p)
 }
 
 func TestPrepareHttpArray(t *testing.T) {
-	p := prepareHttp("INSERT INTO table (arr) VALUES (:value:)", Row{Array{"val1", "val2"}})
+	p, err := prepareHttp("INSERT INTO table (arr) VALUES (:value:)", Row{Array{"val1", "val2"}})
+	assert.NoError(t, err)
 	assert.Equal(t, "INSERT INTO table (arr) VALUES (['val1','val2'])", p)
 }
 
@@ -73,3 +79,107 @@ func BenchmarkPrepareHttp(b *testing.B) {
 		prepareHttp("INSERT INTO t VALUES "+params, args)
 	}
 }
+
+func TestExecQueryParams(t *testing.T) {
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		fmt.Fprint(w, "1")
+	}))
+	defer server.Close()
+
+	conn := NewConn(server.URL, NewHttpTransport())
+	conn.AddParam("user", "default")
+	q := NewQuery("SELECT 1")
+	q.AddParam("max_execution_time", "10")
+
+	_, err := conn.Exec(q, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", got.Get("max_execution_time"))
+	assert.Equal(t, "default", got.Get("user"))
+
+	q = NewQuery("SELECT+1")
+	q.AddParam("max_execution_time", "20")
+	_, err = conn.Exec(q, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "20", got.Get("max_execution_time"))
+	assert.Equal(t, "default", got.Get("user"))
+	assert.Equal(t, "SELECT 1", got.Get("query"))
+}
+
+func TestPrepareHttpErrors(t *testing.T) {
+	_, err := prepareHttp("SELECT :value:, :value:", []interface{}{1})
+	assert.Error(t, err)
+
+	_, err = prepareHttp("SELECT :value:", []interface{}{make(chan int)})
+	assert.Error(t, err)
+
+	conn := NewConn(getHost(), NewHttpTransport())
+	_, err = conn.Exec(NewQuery("SELECT :value:", struct{}{}), false)
+	assert.Error(t, err)
+}
+
+func TestStreamTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		fmt.Fprint(w, "1\n")
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, "2\n")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewCustomTransport(&http.Client{Timeout: 50 * time.Millisecond}))
+
+	// plain requests keep client timeout
+	_, err := conn.Exec(NewQuery("SELECT 1"), false)
+	assert.Error(t, err)
+
+	body, err := conn.Stream(NewQuery("SELECT 1"), false)
+	if assert.NoError(t, err) {
+		data, err := io.ReadAll(body)
+		body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "1\n2\n", string(data))
+	}
+
+	// slow insert body
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("1\n"))
+		time.Sleep(200 * time.Millisecond)
+		pw.Write([]byte("2\n"))
+		pw.Close()
+	}()
+	assert.NoError(t, conn.InsertFrom(context.Background(), "t", "TSV", pr))
+
+	// streams are limited by context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	q := NewQuery("SELECT 1")
+	q.SetContext(ctx)
+	body, err = conn.Stream(q, false)
+	if assert.NoError(t, err) {
+		_, err = io.ReadAll(body)
+		body.Close()
+		assert.Error(t, err)
+	}
+}
+
+func TestExecStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "Service Unavailable")
+	}))
+	defer server.Close()
+	conn := NewConn(server.URL, NewHttpTransport())
+
+	q, err := BuildInsert("clicks", Columns{"id"}, Row{1})
+	assert.NoError(t, err)
+	err = q.Exec(conn)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "503")
+		assert.Contains(t, err.Error(), "Service Unavailable")
+	}
+	assert.Error(t, conn.InsertFrom(context.Background(), "clicks", "CSV", strings.NewReader("1\n")))
+	assert.Error(t, conn.Ping())
+}
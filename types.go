@@ -0,0 +1,247 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnSchema is column name with ClickHouse type, e.g. {"tags", "Array(LowCardinality(String))"}
+type ColumnSchema struct {
+	Name string
+	Type string
+}
+
+// Schema is ordered list of columns used by binary formats
+type Schema []ColumnSchema
+
+// Names returns column names in schema order
+func (s Schema) Names() Columns {
+	res := make(Columns, len(s))
+	for i, c := range s {
+		res[i] = c.Name
+	}
+	return res
+}
+
+// DescribeTable loads insertable columns of table, result can be reused for many inserts
+func DescribeTable(conn Connector, table string) (Schema, error) {
+	iter := NewQuery("DESCRIBE TABLE " + table).Iter(conn)
+	var (
+		res                    Schema
+		name, typ, defaultKind string
+	)
+	for iter.Scan(&name, &typ, &defaultKind) {
+		// these columns are calculated by server and cannot be inserted
+		if defaultKind == "MATERIALIZED" || defaultKind == "ALIAS" {
+			continue
+		}
+		res = append(res, ColumnSchema{Name: name, Type: typ})
+	}
+	if iter.Error() != nil {
+		return nil, iter.Error()
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("Table %s has no columns to insert", table)
+	}
+	return res, nil
+}
+
+// ColumnType is parsed ClickHouse data type, Decimal32(S) and other Decimal aliases have name Decimal
+type ColumnType struct {
+	Name string
+	// nested types of Array, Nullable, LowCardinality, Tuple, Map and Nested
+	Args []*ColumnType
+	// element names of named Tuple and Nested
+	Names []string
+	// precision and scale of Decimal, precision of DateTime64
+	Precision int
+	Scale     int
+	// size of FixedString in bytes
+	Size int
+	// time zone of DateTime and DateTime64, nil if column uses server time zone
+	Location *time.Location
+	// values of Enum8 and Enum16
+	Enum *Enum
+	raw  string
+}
+
+// ParseColumnType parses type names returned by server, e.g. "Array(Nullable(Decimal(18, 2)))"
+func ParseColumnType(s string) (*ColumnType, error) {
+	return parseColumnType(s)
+}
+
+// String returns type as it was written in schema
+func (t *ColumnType) String() string {
+	return t.raw
+}
+
+// parseColumnType parses type definition like Nullable(Decimal(18, 2)) or Tuple(a String, b Array(UInt8))
+func parseColumnType(s string) (*ColumnType, error) {
+	s = strings.TrimSpace(s)
+	t := &ColumnType{Name: s, raw: s}
+
+	pos := strings.IndexByte(s, '(')
+	if pos < 0 {
+		switch s {
+		case "Decimal":
+			t.Precision, t.Scale = 10, 0
+		case "DateTime64":
+			t.Precision = 3
+		case "Int8", "Int16", "Int32", "Int64", "Int128", "Int256",
+			"UInt8", "UInt16", "UInt32", "UInt64", "UInt128", "UInt256",
+			"Float32", "Float64", "Bool", "String", "UUID", "IPv4", "IPv6",
+			"Date", "Date32", "DateTime", "Nothing":
+		default:
+			return nil, fmt.Errorf("Unsupported column type %q", s)
+		}
+		return t, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("Cannot parse column type %q", s)
+	}
+
+	t.Name = strings.TrimSpace(s[:pos])
+	args := splitTypeArgs(s[pos+1 : len(s)-1])
+
+	var err error
+	switch t.Name {
+	case "Nullable", "Array", "LowCardinality":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("Type %s expects one argument: %q", t.Name, s)
+		}
+		t.Args, err = parseTypeArgs(args)
+	case "Map":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Type Map expects two arguments: %q", s)
+		}
+		t.Args, err = parseTypeArgs(args)
+	case "SimpleAggregateFunction":
+		// it is stored the same way as its argument type
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Type SimpleAggregateFunction expects two arguments: %q", s)
+		}
+		return parseColumnType(args[1])
+	case "Tuple", "Nested":
+		for _, arg := range args {
+			name, typ := splitTypeName(arg)
+			item, err := parseColumnType(typ)
+			if err != nil {
+				return nil, err
+			}
+			t.Names = append(t.Names, name)
+			t.Args = append(t.Args, item)
+		}
+	case "FixedString":
+		t.Size, err = typeIntArg(args, 0)
+	case "Decimal":
+		if t.Precision, err = typeIntArg(args, 0); err == nil && len(args) > 1 {
+			t.Scale, err = typeIntArg(args, 1)
+		}
+	case "Decimal32", "Decimal64", "Decimal128", "Decimal256":
+		t.Precision = map[string]int{"Decimal32": 9, "Decimal64": 18, "Decimal128": 38, "Decimal256": 76}[t.Name]
+		t.Scale, err = typeIntArg(args, 0)
+		t.Name = "Decimal"
+	case "DateTime":
+		t.Location, err = typeLocationArg(args, 0)
+	case "DateTime64":
+		if t.Precision, err = typeIntArg(args, 0); err == nil {
+			t.Location, err = typeLocationArg(args, 1)
+		}
+		if err == nil && (t.Precision < 0 || t.Precision > 9) {
+			err = fmt.Errorf("precision %d is out of range", t.Precision)
+		}
+	case "Enum8", "Enum16":
+		t.Enum, err = ParseEnum(s)
+	default:
+		return nil, fmt.Errorf("Unsupported column type %q", s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse column type %q: %v", s, err)
+	}
+	if t.Name == "Decimal" && (t.Precision < 1 || t.Precision > 76 || t.Scale < 0 || t.Scale > t.Precision) {
+		return nil, fmt.Errorf("Bad precision or scale of %q", s)
+	}
+	return t, nil
+}
+
+func parseTypeArgs(args []string) ([]*ColumnType, error) {
+	res := make([]*ColumnType, len(args))
+	for i, arg := range args {
+		t, err := parseColumnType(arg)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = t
+	}
+	return res, nil
+}
+
+// splitTypeArgs splits type arguments by commas outside of parentheses and quotes
+func splitTypeArgs(s string) []string {
+	var (
+		res   []string
+		depth int
+		start int
+		quote bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote && c == '\\':
+			i++
+		case c == '\'':
+			quote = !quote
+		case quote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			res = append(res, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(res) > 0 {
+		res = append(res, last)
+	}
+	return res
+}
+
+// splitTypeName separates element name in named tuples: "a Array(String)" -> "a", "Array(String)"
+func splitTypeName(s string) (string, string) {
+	if strings.HasPrefix(s, "`") {
+		if pos := strings.IndexByte(s[1:], '`'); pos >= 0 {
+			return s[1 : pos+1], strings.TrimSpace(s[pos+2:])
+		}
+	}
+	end := strings.IndexByte(s, '(')
+	if end < 0 {
+		end = len(s)
+	}
+	if pos := strings.IndexByte(s[:end], ' '); pos >= 0 {
+		return s[:pos], strings.TrimSpace(s[pos+1:])
+	}
+	return "", s
+}
+
+func typeIntArg(args []string, i int) (int, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("argument %d is missing", i)
+	}
+	return strconv.Atoi(args[i])
+}
+
+func typeLocationArg(args []string, i int) (*time.Location, error) {
+	if i >= len(args) {
+		return nil, nil
+	}
+	l, err := parseLiteral(args[i])
+	if err != nil {
+		return nil, err
+	}
+	if l.kind != literalString {
+		return nil, fmt.Errorf("time zone %s is not a string", args[i])
+	}
+	return time.LoadLocation(l.text)
+}
@@ -0,0 +1,75 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColumnType(t *testing.T) {
+	typ, err := parseColumnType("Nullable(Decimal(18, 2))")
+	assert.NoError(t, err)
+	assert.Equal(t, "Nullable", typ.Name)
+	assert.Equal(t, "Decimal", typ.Args[0].Name)
+	assert.Equal(t, 18, typ.Args[0].Precision)
+	assert.Equal(t, 2, typ.Args[0].Scale)
+
+	typ, err = parseColumnType("Decimal128(4)")
+	assert.NoError(t, err)
+	assert.Equal(t, "Decimal", typ.Name)
+	assert.Equal(t, 38, typ.Precision)
+	assert.Equal(t, 4, typ.Scale)
+
+	typ, err = parseColumnType("Map(LowCardinality(String), Array(Tuple(a UInt8, `b c` DateTime64(6, 'Asia/Tokyo'))))")
+	assert.NoError(t, err)
+	assert.Equal(t, "Map", typ.Name)
+	assert.Equal(t, "LowCardinality", typ.Args[0].Name)
+	tuple := typ.Args[1].Args[0]
+	assert.Equal(t, []string{"a", "b c"}, tuple.Names)
+	assert.Equal(t, 6, tuple.Args[1].Precision)
+	assert.Equal(t, "Asia/Tokyo", tuple.Args[1].Location.String())
+
+	typ, err = parseColumnType("Enum8('a, b' = 1, 'c(' = 2)")
+	assert.NoError(t, err)
+	v, _ := typ.Enum.Value("c(")
+	assert.Equal(t, int16(2), v)
+
+	typ, err = parseColumnType("SimpleAggregateFunction(sum, UInt64)")
+	assert.NoError(t, err)
+	assert.Equal(t, "UInt64", typ.Name)
+
+	typ, err = parseColumnType("FixedString(16)")
+	assert.NoError(t, err)
+	assert.Equal(t, 16, typ.Size)
+
+	for _, bad := range []string{"Foo", "Array(String", "Array(Foo)", "Map(String)", "Decimal(10, 20)", "DateTime64(12)", "DateTime('Nowhere/City')"} {
+		_, err = parseColumnType(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestDescribeTable(t *testing.T) {
+	tr := getMockTransport("id\tUInt64\t\t\t\t\t\nname\tLowCardinality(String)\tDEFAULT\t''\t\t\t\nlen\tUInt32\tMATERIALIZED\tlength(name)\t\t\t\n")
+	conn := NewConn(getHost(), tr)
+	schema, err := DescribeTable(conn, "t")
+	assert.NoError(t, err)
+	assert.Equal(t, Schema{{"id", "UInt64"}, {"name", "LowCardinality(String)"}}, schema)
+	assert.Equal(t, Columns{"id", "name"}, schema.Names())
+
+	_, err = DescribeTable(NewConn(getHost(), getMockTransport("")), "t")
+	assert.Error(t, err)
+}
+
+func TestParseColumnType_Exported(t *testing.T) {
+	typ, err := ParseColumnType("Map(String, Array(DateTime64(3, 'UTC')))")
+	assert.NoError(t, err)
+	assert.Equal(t, "Map", typ.Name)
+	assert.Equal(t, "String", typ.Args[0].Name)
+	assert.Equal(t, "Array", typ.Args[1].Name)
+	assert.Equal(t, 3, typ.Args[1].Args[0].Precision)
+	assert.Equal(t, "UTC", typ.Args[1].Args[0].Location.String())
+	assert.Equal(t, "DateTime64(3, 'UTC')", typ.Args[1].Args[0].String())
+
+	_, err = ParseColumnType("Array(")
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package clickhouse
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// UUID is value of UUID column
+type UUID [16]byte
+
+// ParseUUID parses canonical text form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("Cannot parse %q as UUID", s)
+	}
+	src := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err := hex.Decode(u[:], src); err != nil {
+		return u, fmt.Errorf("Cannot parse %q as UUID: %v", s, err)
+	}
+	return u, nil
+}
+
+// String returns canonical text form of UUID
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+// MarshalText is used by JSON encoding
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText is used by JSON decoding, e.g. in ExecScan
+func (u *UUID) UnmarshalText(data []byte) error {
+	res, err := ParseUUID(string(data))
+	if err != nil {
+		return err
+	}
+	*u = res
+	return nil
+}
@@ -0,0 +1,32 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUUID(t *testing.T) {
+	u, err := ParseUUID("417ddc5d-e556-4d27-95dd-a34d84e46a50")
+	assert.NoError(t, err)
+	assert.Equal(t, UUID{0x41, 0x7d, 0xdc, 0x5d, 0xe5, 0x56, 0x4d, 0x27, 0x95, 0xdd, 0xa3, 0x4d, 0x84, 0xe4, 0x6a, 0x50}, u)
+	assert.Equal(t, "417ddc5d-e556-4d27-95dd-a34d84e46a50", u.String())
+
+	for _, s := range []string{"", "417ddc5d-e556-4d27-95dd-a34d84e46a5", "417ddc5d+e556-4d27-95dd-a34d84e46a50", "417ddc5d-e556-4d27-95dd-a34d84e46aXX"} {
+		_, err = ParseUUID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestUUID_JSON(t *testing.T) {
+	var obj struct {
+		ID UUID `json:"id"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"00000000-0000-0000-0000-000000000001"}`), &obj))
+	assert.Equal(t, UUID{15: 1}, obj.ID)
+
+	res, err := json.Marshal(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"00000000-0000-0000-0000-000000000001"}`, string(res))
+}